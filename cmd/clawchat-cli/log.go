@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxLogSize is the size at which the diagnostics log is rotated. One old
+// file is kept, as path.1, so the log never takes more than twice this.
+const maxLogSize = 5 << 20
+
+// rotatingLog appends to a file and rotates it once it reaches max bytes.
+type rotatingLog struct {
+	path string
+	max  int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openLog opens the log at path for appending, rotating it first if it is
+// already over max.
+func openLog(path string, max int64) (*rotatingLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	l := &rotatingLog{path: path, max: max}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	if l.size >= max {
+		if err := l.rotate(); err != nil {
+			l.f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// rotate moves the current file to path.1, replacing any older one, and
+// starts a new one.
+func (l *rotatingLog) rotate() error {
+	l.f.Close()
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func (l *rotatingLog) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	if l.size > 0 && l.size+int64(len(b)) > l.max {
+		if err := l.rotate(); err != nil {
+			l.f = nil
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "clawchat.log")
+	l, err := openLog(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "third\n" {
+		t.Errorf("log = %q, want the last line", got)
+	}
+	if got := readFile(t, path+".1"); got != "second\n" {
+		t.Errorf("rotated log = %q, want the line before", got)
+	}
+
+	// A log already over the cap is rotated when opened.
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 20)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err = openLog(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if got := readFile(t, path); got != "" {
+		t.Errorf("log = %q, want a fresh file", got)
+	}
+	if _, err := l.Write([]byte("late")); err == nil {
+		t.Error("write after Close succeeded")
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
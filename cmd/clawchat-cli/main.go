@@ -1,11 +1,18 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
 	"github.com/ngmaloney/clawchat-cli/internal/ui"
 )
 
@@ -17,6 +24,11 @@ var (
 )
 
 func main() {
+	// ssh re-executes us as its SSH_ASKPASS helper to relay prompts to the TUI.
+	if tunnel.IsAskpass() {
+		os.Exit(tunnel.RunAskpass(os.Args[1:]))
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "clawchat-cli: config error: %v\n", err)
@@ -32,9 +44,63 @@ func main() {
 		os.Exit(1)
 	}
 
-	app := ui.New(cfg)
-	p := tea.NewProgram(app, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	// Subcommands run without the TUI.
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "session" {
+			fmt.Fprintf(os.Stderr, "clawchat-cli: unknown command %q\n", args[0])
+			os.Exit(2)
+		}
+		connect := func() (*gateway.Client, func(), error) { return connectGateway(cfg) }
+		if err := runSession(args[1:], connect, os.Stdin, os.Stdout); err != nil {
+			if errors.Is(err, errUsage) {
+				os.Exit(2)
+			}
+			fmt.Fprintf(os.Stderr, "clawchat-cli: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Tunnel diagnostics go to the log file; a log that cannot be opened
+	// only costs the on-disk copy, so it is not fatal. It is closed
+	// explicitly below, as os.Exit skips deferred calls.
+	var log io.Writer
+	var logFile *rotatingLog
+	if path := cfg.LogPath(); path != "" {
+		if l, err := openLog(path, maxLogSize); err == nil {
+			logFile, log = l, l
+		}
+	}
+
+	app := ui.New(cfg, log)
+	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithoutSignalHandler(), tea.WithFPS(cfg.FrameRate()))
+
+	// SIGTERM, SIGHUP (e.g. the SSH session hosting the TUI dropped) and a
+	// SIGINT from outside the terminal end the program; a second signal
+	// stops waiting for it to wind down.
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		if _, ok := <-sigs; !ok {
+			return
+		}
+		p.Quit()
+		if _, ok := <-sigs; ok {
+			p.Kill()
+		}
+	}()
+
+	_, err = p.Run()
+	signal.Stop(sigs)
+	close(sigs)
+
+	// Whatever ended the program, take the tunnel and connection down with it.
+	app.Shutdown()
+	if logFile != nil {
+		logFile.Close()
+	}
+
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
 		fmt.Fprintf(os.Stderr, "clawchat-cli: %v\n", err)
 		os.Exit(1)
 	}
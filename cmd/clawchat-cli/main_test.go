@@ -0,0 +1,102 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// TestMain lets the tests run the CLI itself by re-executing the test binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("CLAWCHAT_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestSignalsLeaveNoTunnelProcess kills the CLI while its tunnel command is
+// still starting and checks the command was torn down with it.
+func TestSignalsLeaveNoTunnelProcess(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT} {
+		t.Run(sig.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			pidFile := filepath.Join(dir, "pid")
+			cfgFile := filepath.Join(dir, "config.yaml")
+			// The tunnel command never listens, so the CLI stays on the
+			// connecting screen until it is signalled.
+			cfg := fmt.Sprintf(`gateway_url: ws://127.0.0.1:1
+token: test
+log_file: %s
+tunnel:
+  command: "sh -c 'echo $$ > %s; exec sleep 60' {local_port}"
+  ready_timeout: 60s
+`, filepath.Join(dir, "log"), pidFile)
+			if err := os.WriteFile(cfgFile, []byte(cfg), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// The TUI needs a terminal; give it a pseudo-terminal and drain it.
+			cmd := exec.Command(os.Args[0])
+			cmd.Env = append(os.Environ(), "CLAWCHAT_TEST_MAIN=1", "CLAWCHAT_CONFIG="+cfgFile, "TERM=dumb")
+			tty, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 24, Cols: 80})
+			if err != nil {
+				t.Fatalf("starting CLI on a pty: %v", err)
+			}
+			defer tty.Close()
+			go func() { _, _ = io.Copy(io.Discard, tty) }()
+			exited := make(chan error, 1)
+			go func() { exited <- cmd.Wait() }()
+
+			pid := waitPID(t, pidFile, exited)
+			if err := cmd.Process.Signal(sig); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-exited:
+			case <-time.After(10 * time.Second):
+				_ = cmd.Process.Kill()
+				t.Fatal("CLI did not exit after the signal")
+			}
+
+			if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+				log, _ := os.ReadFile(filepath.Join(dir, "log"))
+				t.Errorf("tunnel process %d orphaned (kill 0: %v); log:\n%s", pid, err, log)
+			}
+		})
+	}
+}
+
+func waitPID(t *testing.T, path string, exited <-chan error) int {
+	t.Helper()
+	deadline := time.After(10 * time.Second)
+	for {
+		if data, err := os.ReadFile(path); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		select {
+		case err := <-exited:
+			t.Fatalf("CLI exited before starting the tunnel: %v", err)
+		case <-deadline:
+			t.Fatal("tunnel command never started")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
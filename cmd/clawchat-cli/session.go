@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
+)
+
+const sessionUsage = `usage: clawchat-cli [flags] session <command>
+
+commands:
+  list                                         list sessions
+  new [--agent ID] [--model M] [--label L]     create a session and print its key
+  rename KEY LABEL                             set a session's label ("" clears it)
+  reset [--yes] KEY                            clear a session's history
+  delete [--yes] KEY                           delete a session
+`
+
+// errUsage is returned for a malformed command line; the usage has been
+// printed.
+var errUsage = errors.New("usage")
+
+// connectFunc opens a gateway connection for a subcommand. close tears it
+// down, with its tunnel.
+type connectFunc func() (client *gateway.Client, close func(), err error)
+
+// runSession runs the session subcommand. Destructive commands ask on in
+// unless --yes is given; connect is only called once the command is known
+// to go ahead.
+func runSession(args []string, connect connectFunc, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, sessionUsage)
+		return errUsage
+	}
+	fs := flag.NewFlagSet("session "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() { fmt.Fprint(out, sessionUsage) }
+	var (
+		agent = fs.String("agent", "", "agent ID")
+		model = fs.String("model", "", "model")
+		label = fs.String("label", "", "label")
+		yes   = fs.Bool("yes", false, "do not ask for confirmation")
+	)
+	// Flags may follow positionals, as in "delete KEY --yes", but the flag
+	// package stops at the first positional: resume after each one. After
+	// "--" everything is positional.
+	var rest []string
+	for left := args[1:]; ; {
+		if err := fs.Parse(left); err != nil {
+			return errUsage
+		}
+		after := fs.Args()
+		if len(after) == 0 {
+			break
+		}
+		if n := len(left) - len(after); n > 0 && left[n-1] == "--" {
+			rest = append(rest, after...)
+			break
+		}
+		rest = append(rest, after[0])
+		left = after[1:]
+	}
+	want := map[string]int{"list": 0, "new": 0, "rename": 2, "reset": 1, "delete": 1}
+	n, ok := want[args[0]]
+	if !ok || len(rest) != n {
+		fmt.Fprint(out, sessionUsage)
+		return errUsage
+	}
+
+	switch args[0] {
+	case "reset", "delete":
+		if !*yes && !confirm(in, out, fmt.Sprintf("%s session %s?", strings.ToUpper(args[0][:1])+args[0][1:], rest[0])) {
+			return nil
+		}
+	}
+
+	client, closeConn, err := connect()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	switch args[0] {
+	case "list":
+		sessions, err := client.ListSessions()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Label, s.Model)
+		}
+		return w.Flush()
+	case "new":
+		s, err := client.CreateSession(gateway.NewSession{Agent: *agent, Model: *model, Label: *label})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, s.Key)
+		return nil
+	case "rename":
+		return client.RenameSession(rest[0], rest[1])
+	case "reset":
+		return client.ResetSession(rest[0])
+	default:
+		return client.DeleteSession(rest[0])
+	}
+}
+
+// confirm asks question on out and reports whether the answer read from in
+// is yes.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// connectGateway connects as the TUI does, through the configured tunnel if
+// any. There is no one to answer SSH prompts, so the tunnel runs
+// non-interactively: keys must be in the agent or unencrypted.
+func connectGateway(cfg *config.Config) (*gateway.Client, func(), error) {
+	var tun *tunnel.Tunnel
+	var err error
+	switch {
+	case cfg.SSHEnabled():
+		tun, err = tunnel.Start(cfg.SSH, tunnel.Options{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("SSH tunnel: %w", err)
+		}
+	case cfg.TunnelEnabled():
+		tun, err = tunnel.StartCommand(cfg.Tunnel, tunnel.Options{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("tunnel: %w", err)
+		}
+	}
+
+	opts := gateway.Options{
+		URL:         cfg.GatewayURL,
+		Token:       cfg.Token,
+		AuthMode:    gateway.AuthMode(cfg.AuthMode()),
+		Password:    cfg.Auth.Password,
+		DeviceToken: cfg.Auth.DeviceToken,
+		Scopes:      cfg.RequestedScopes(),
+	}
+	if tun != nil {
+		opts.URL = tun.GatewayURL()
+		opts.NetDialContext = tun.DialContext
+	}
+	client := gateway.New(opts)
+	closeAll := func() {
+		client.Close()
+		if tun != nil {
+			tun.Stop()
+		}
+	}
+	if err := client.Connect(); err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("gateway: %w", err)
+	}
+	return client, closeAll, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+func TestSessionCommandLine(t *testing.T) {
+	errConnect := errors.New("connect called")
+	for _, tt := range []struct {
+		args  []string
+		input string
+		want  error // errConnect: went ahead; nil: stopped before connecting
+	}{
+		{args: nil, want: errUsage},
+		{args: []string{"bogus"}, want: errUsage},
+		{args: []string{"rename", "agent:main:x"}, want: errUsage},
+		{args: []string{"list"}, want: errConnect},
+		{args: []string{"new", "--agent", "ops", "--model", "m"}, want: errConnect},
+		{args: []string{"delete", "agent:main:x"}, input: "n\n", want: nil},
+		{args: []string{"delete", "agent:main:x"}, input: "", want: nil},
+		{args: []string{"delete", "agent:main:x"}, input: "y\n", want: errConnect},
+		{args: []string{"reset", "--yes", "agent:main:x"}, want: errConnect},
+		{args: []string{"delete", "agent:main:x", "--yes"}, want: errConnect},
+		{args: []string{"delete", "agent:main:x", "agent:main:y", "--yes"}, want: errUsage},
+		{args: []string{"rename", "agent:main:x", "--", "-draft-"}, want: errConnect},
+		{args: []string{"new", "--agent", "ops", "extra"}, want: errUsage},
+	} {
+		connect := func() (*gateway.Client, func(), error) { return nil, nil, errConnect }
+		var out strings.Builder
+		err := runSession(tt.args, connect, strings.NewReader(tt.input), &out)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%v (input %q): err = %v, want %v", tt.args, tt.input, err, tt.want)
+		}
+		if tt.input != "" && !strings.Contains(out.String(), "Delete session agent:main:x? [y/N]") {
+			t.Errorf("%v: no confirmation asked: %q", tt.args, out.String())
+		}
+	}
+}
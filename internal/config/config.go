@@ -3,33 +3,124 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Host key policies accepted in ssh.host_key_policy.
+const (
+	HostKeyStrict    = "strict"     // only hosts already in known_hosts
+	HostKeyAcceptNew = "accept-new" // trust new hosts, reject changed keys (default)
+	HostKeyAsk       = "ask"        // confirm new host fingerprints in the TUI
+)
+
 // SSH holds SSH tunnel configuration.
+// Host may be a Host alias from ~/.ssh/config; its HostName, User, Port,
+// IdentityFile and ProxyJump apply unless set here explicitly.
 type SSH struct {
-	Host       string `yaml:"host"`
-	Port       int    `yaml:"port"`
-	User       string `yaml:"user"`
-	KeyPath    string `yaml:"key_path"`
-	RemotePort int    `yaml:"remote_port"`
+	Host       string   `yaml:"host"`
+	Port       int      `yaml:"port,omitempty"`
+	User       string   `yaml:"user,omitempty"`
+	KeyPath    string   `yaml:"key_path,omitempty"`
+	RemoteHost string   `yaml:"remote_host,omitempty"` // gateway host as seen from the SSH host (default 127.0.0.1)
+	RemotePort int      `yaml:"remote_port"`
+	JumpHosts  []string `yaml:"jump_hosts,omitempty"` // [user@]host[:port], outermost first
+	Backend    string   `yaml:"backend,omitempty"`    // auto (default), native, exec
+
+	// ControlPath points at an OpenSSH ControlMaster socket. When a master is
+	// running there, the forward is added to it with ssh -O forward instead
+	// of opening a new connection.
+	ControlPath string `yaml:"control_path,omitempty"`
+
+	HostKeyPolicy string `yaml:"host_key_policy,omitempty"` // strict, accept-new (default), ask
+}
+
+// Tunnel configures a command tunnel: any program that forwards a local port
+// to the gateway, such as kubectl port-forward or cloudflared access tcp.
+// {local_port} in Command is replaced by the port the client connects to.
+type Tunnel struct {
+	Command      string        `yaml:"command"`
+	ReadyPattern string        `yaml:"ready_pattern,omitempty"` // regexp on the command's output; default: wait for the port
+	ReadyTimeout time.Duration `yaml:"ready_timeout,omitempty"` // default 30s
+}
+
+// Auth modes accepted in the auth section.
+const (
+	AuthModeToken       = "token"
+	AuthModePassword    = "password"
+	AuthModeDeviceToken = "device-token"
+)
+
+// Auth selects how the client authenticates to the gateway.
+// The shared token itself stays at the top level for backward compatibility.
+type Auth struct {
+	Mode        string `yaml:"mode,omitempty"` // token (default), password, device-token
+	Password    string `yaml:"password,omitempty"`
+	DeviceToken string `yaml:"device_token,omitempty"`
+}
+
+// UI holds display settings.
+type UI struct {
+	FrameRate       int `yaml:"frame_rate,omitempty"`        // redraws per second while a reply streams (default 30)
+	HistoryPageSize int `yaml:"history_page_size,omitempty"` // messages loaded on connect and per scroll-back page (default 50)
+
+	// StatusBar lists the status bar segments, left to right. Unset shows
+	// DefaultStatusBar; an empty list hides the bar.
+	StatusBar []string `yaml:"status_bar,omitempty"`
 }
 
+// Status bar segments accepted in ui.status_bar.
+var StatusSegments = []string{
+	"model", "thinking", "verbose", "tokens", "latency", "tunnel", "reconnect",
+	"connection", "gateway", "session",
+}
+
+// DefaultStatusBar is the status bar layout when none is configured.
+var DefaultStatusBar = []string{"model", "thinking", "verbose", "tokens", "latency", "tunnel", "reconnect"}
+
+// DefaultFrameRate is the streaming redraw rate when none is configured.
+const DefaultFrameRate = 30
+
+// DefaultHistoryPageSize is how many messages are loaded at a time when
+// none is configured.
+const DefaultHistoryPageSize = 50
+
 // Config is the top-level application configuration.
 // Priority: CLI flags > environment variables > config file defaults.
 type Config struct {
-	GatewayURL string `yaml:"gateway_url"`
-	Token      string `yaml:"token"`
-	SessionKey string `yaml:"session_key"`
-	SSH        *SSH   `yaml:"ssh,omitempty"`
+	GatewayURL string   `yaml:"gateway_url"`
+	Token      string   `yaml:"token"`
+	SessionKey string   `yaml:"session_key"`
+	Auth       Auth     `yaml:"auth,omitempty"`
+	Scopes     []string `yaml:"scopes,omitempty"`    // operator scopes to request (default: read, write)
+	Approvals  bool     `yaml:"approvals,omitempty"` // also request operator.approvals and answer exec approvals
+	ReadOnly   bool     `yaml:"read_only,omitempty"` // request only read scopes and disable sending
+	SSH        *SSH     `yaml:"ssh,omitempty"`
+	Tunnel     *Tunnel  `yaml:"tunnel,omitempty"`
+	LogFile    string   `yaml:"log_file,omitempty"` // tunnel diagnostics log (default: next to the config file)
+	UI         UI       `yaml:"ui,omitempty"`
 }
 
 // Load reads config from file, applies env overrides, then flag overrides.
 func Load() (*Config, error) {
+	return load(flag.CommandLine, os.Args[1:])
+}
+
+// Reload reads the config file and environment again and re-applies the
+// command-line flags, e.g. after the file was edited. Load must have
+// succeeded first.
+func Reload() (*Config, error) {
+	return load(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+}
+
+func load(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := defaults()
 
 	// 1. Config file
@@ -50,6 +141,41 @@ func Load() (*Config, error) {
 	if v := os.Getenv("CLAWCHAT_SESSION"); v != "" {
 		cfg.SessionKey = v
 	}
+	if v := os.Getenv("CLAWCHAT_AUTH_MODE"); v != "" {
+		cfg.Auth.Mode = v
+	}
+	if v := os.Getenv("OPENCLAW_PASSWORD"); v != "" {
+		cfg.Auth.Password = v
+	}
+	if v := os.Getenv("OPENCLAW_DEVICE_TOKEN"); v != "" {
+		cfg.Auth.DeviceToken = v
+	}
+	if v := os.Getenv("CLAWCHAT_SCOPES"); v != "" {
+		cfg.Scopes = splitList(v)
+	}
+	if v := os.Getenv("CLAWCHAT_APPROVALS"); v == "1" || v == "true" {
+		cfg.Approvals = true
+	}
+	if v := os.Getenv("CLAWCHAT_READ_ONLY"); v == "1" || v == "true" {
+		cfg.ReadOnly = true
+	}
+	if v := os.Getenv("CLAWCHAT_FRAME_RATE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("CLAWCHAT_FRAME_RATE: %w", err)
+		}
+		cfg.UI.FrameRate = n
+	}
+	if v := os.Getenv("CLAWCHAT_HISTORY_PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("CLAWCHAT_HISTORY_PAGE_SIZE: %w", err)
+		}
+		cfg.UI.HistoryPageSize = n
+	}
+	if v := os.Getenv("CLAWCHAT_LOG_FILE"); v != "" {
+		cfg.LogFile = v
+	}
 
 	// SSH env
 	if v := os.Getenv("CLAWCHAT_SSH_HOST"); v != "" {
@@ -58,20 +184,60 @@ func Load() (*Config, error) {
 		}
 		cfg.SSH.Host = v
 	}
+	if v := os.Getenv("CLAWCHAT_SSH_REMOTE_HOST"); v != "" && cfg.SSH != nil {
+		cfg.SSH.RemoteHost = v
+	}
+	if v := os.Getenv("CLAWCHAT_SSH_JUMP"); v != "" && cfg.SSH != nil {
+		cfg.SSH.JumpHosts = splitList(v)
+	}
+	if v := os.Getenv("CLAWCHAT_SSH_HOST_KEY_POLICY"); v != "" && cfg.SSH != nil {
+		cfg.SSH.HostKeyPolicy = v
+	}
+	if v := os.Getenv("CLAWCHAT_SSH_BACKEND"); v != "" && cfg.SSH != nil {
+		cfg.SSH.Backend = v
+	}
+	if v := os.Getenv("CLAWCHAT_SSH_CONTROL_PATH"); v != "" && cfg.SSH != nil {
+		cfg.SSH.ControlPath = v
+	}
 
-	// 3. CLI flags (defined here so help text is accurate)
+	if v := os.Getenv("CLAWCHAT_TUNNEL_COMMAND"); v != "" {
+		if cfg.Tunnel == nil {
+			cfg.Tunnel = &Tunnel{}
+		}
+		cfg.Tunnel.Command = v
+	}
+
+	// 3. CLI flags (defined here so help text is accurate). Secrets default
+	// to empty and are merged below, so --help never prints them.
 	var (
-		flagGateway    = flag.String("gateway", cfg.GatewayURL, "Gateway WebSocket URL (ws:// or wss://)")
-		flagToken      = flag.String("token", cfg.Token, "Gateway auth token")
-		flagSession    = flag.String("session", cfg.SessionKey, "Session key to connect to (default: first available)")
-		flagSSHHost    = flag.String("ssh-host", "", "SSH tunnel host")
-		flagSSHPort    = flag.Int("ssh-port", 22, "SSH tunnel port")
-		flagSSHUser    = flag.String("ssh-user", "", "SSH tunnel user")
-		flagSSHKey     = flag.String("ssh-key", "", "Path to SSH private key")
-		flagSSHRemote  = flag.Int("ssh-remote-port", 18789, "Remote gateway port to forward")
-		flagVersion    = flag.Bool("version", false, "Print version and exit")
+		flagGateway    = fs.String("gateway", cfg.GatewayURL, "Gateway WebSocket URL (ws:// or wss://)")
+		flagToken      = fs.String("token", "", "Gateway auth token (visible in the process list; prefer OPENCLAW_TOKEN or the config file)")
+		flagSession    = fs.String("session", cfg.SessionKey, "Session key to connect to (default: first available)")
+		flagAuthMode   = fs.String("auth-mode", cfg.Auth.Mode, "Gateway auth mode: token, password or device-token")
+		flagPassword   = fs.String("password", "", "Gateway password (auth mode password; prefer OPENCLAW_PASSWORD)")
+		flagDevToken   = fs.String("device-token", "", "Device token (auth mode device-token; prefer OPENCLAW_DEVICE_TOKEN)")
+		flagScopes     = fs.String("scopes", strings.Join(cfg.Scopes, ","), "Comma-separated operator scopes to request")
+		flagApprovals  = fs.Bool("approvals", cfg.Approvals, "Request operator.approvals and answer exec approval requests")
+		flagReadOnly   = fs.Bool("read-only", cfg.ReadOnly, "Spectator mode: request read scopes only and disable sending")
+		flagLogFile    = fs.String("log-file", cfg.LogFile, "Write tunnel diagnostics to this file (\"-\" to disable)")
+		flagFrameRate  = fs.Int("frame-rate", cfg.UI.FrameRate, "Redraws per second while a reply streams (default 30)")
+		flagPageSize   = fs.Int("history-page-size", cfg.UI.HistoryPageSize, "Messages loaded on connect and per scroll-back page (default 50)")
+		flagSSHHost    = fs.String("ssh-host", "", "SSH tunnel host")
+		flagSSHPort    = fs.Int("ssh-port", 0, "SSH tunnel port (default: from ~/.ssh/config, else 22)")
+		flagSSHUser    = fs.String("ssh-user", "", "SSH tunnel user (default: from ~/.ssh/config, else local user)")
+		flagSSHKey     = fs.String("ssh-key", "", "Path to SSH private key")
+		flagSSHRHost   = fs.String("ssh-remote-host", "", "Gateway host to forward to, as seen from the SSH host (default 127.0.0.1)")
+		flagSSHRemote  = fs.Int("ssh-remote-port", 18789, "Remote gateway port to forward")
+		flagSSHJump    = fs.String("ssh-jump", "", "Comma-separated jump hosts ([user@]host[:port]) to reach the SSH host")
+		flagSSHBackend = fs.String("ssh-backend", "", "SSH tunnel backend: auto, native or exec")
+		flagSSHHostKey = fs.String("ssh-host-key-policy", "", "SSH host key policy: strict, accept-new or ask")
+		flagSSHControl = fs.String("ssh-control-path", "", "OpenSSH ControlMaster socket to attach the forward to")
+		flagTunnelCmd  = fs.String("tunnel-command", "", "Command that forwards {local_port} to the gateway (e.g. kubectl port-forward)")
+		flagVersion    = fs.Bool("version", false, "Print version and exit")
 	)
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	if *flagVersion {
 		fmt.Println("clawchat-cli dev")
@@ -87,6 +253,23 @@ func Load() (*Config, error) {
 	if *flagSession != "" {
 		cfg.SessionKey = *flagSession
 	}
+	if *flagAuthMode != "" {
+		cfg.Auth.Mode = *flagAuthMode
+	}
+	if *flagPassword != "" {
+		cfg.Auth.Password = *flagPassword
+	}
+	if *flagDevToken != "" {
+		cfg.Auth.DeviceToken = *flagDevToken
+	}
+	if *flagScopes != "" {
+		cfg.Scopes = splitList(*flagScopes)
+	}
+	cfg.Approvals = *flagApprovals
+	cfg.ReadOnly = *flagReadOnly
+	cfg.LogFile = *flagLogFile
+	cfg.UI.FrameRate = *flagFrameRate
+	cfg.UI.HistoryPageSize = *flagPageSize
 	if *flagSSHHost != "" {
 		if cfg.SSH == nil {
 			cfg.SSH = &SSH{}
@@ -97,6 +280,28 @@ func Load() (*Config, error) {
 		cfg.SSH.KeyPath = *flagSSHKey
 		cfg.SSH.RemotePort = *flagSSHRemote
 	}
+	if *flagSSHRHost != "" && cfg.SSH != nil {
+		cfg.SSH.RemoteHost = *flagSSHRHost
+	}
+	if *flagSSHJump != "" && cfg.SSH != nil {
+		cfg.SSH.JumpHosts = splitList(*flagSSHJump)
+	}
+	if *flagSSHHostKey != "" && cfg.SSH != nil {
+		cfg.SSH.HostKeyPolicy = *flagSSHHostKey
+	}
+	if *flagSSHBackend != "" && cfg.SSH != nil {
+		cfg.SSH.Backend = *flagSSHBackend
+	}
+	if *flagSSHControl != "" && cfg.SSH != nil {
+		cfg.SSH.ControlPath = *flagSSHControl
+	}
+
+	if *flagTunnelCmd != "" {
+		if cfg.Tunnel == nil {
+			cfg.Tunnel = &Tunnel{}
+		}
+		cfg.Tunnel.Command = *flagTunnelCmd
+	}
 
 	return cfg, nil
 }
@@ -119,25 +324,160 @@ func (c *Config) Validate() error {
 	if c.GatewayURL == "" {
 		return fmt.Errorf("gateway URL is required (--gateway or OPENCLAW_GATEWAY_URL)")
 	}
-	if c.Token == "" {
-		return fmt.Errorf("auth token is required (--token or OPENCLAW_TOKEN)")
+	switch c.AuthMode() {
+	case AuthModeToken:
+		if c.Token == "" {
+			return fmt.Errorf("auth token is required (--token or OPENCLAW_TOKEN)")
+		}
+	case AuthModePassword:
+		if c.Auth.Password == "" {
+			return fmt.Errorf("password is required for auth mode %q (--password or OPENCLAW_PASSWORD)", AuthModePassword)
+		}
+	case AuthModeDeviceToken:
+		if c.Auth.DeviceToken == "" {
+			return fmt.Errorf("device token is required for auth mode %q (--device-token or OPENCLAW_DEVICE_TOKEN)", AuthModeDeviceToken)
+		}
+	default:
+		return fmt.Errorf("unknown auth mode %q (want %s, %s or %s)", c.Auth.Mode, AuthModeToken, AuthModePassword, AuthModeDeviceToken)
 	}
 	if c.SSH != nil {
 		if c.SSH.Host == "" {
 			return fmt.Errorf("ssh-host is required when using SSH tunnel")
 		}
-		if c.SSH.User == "" {
-			return fmt.Errorf("ssh-user is required when using SSH tunnel")
+		switch c.SSH.Backend {
+		case "", "auto", "native", "exec":
+		default:
+			return fmt.Errorf("unknown ssh backend %q (want auto, native or exec)", c.SSH.Backend)
+		}
+		if c.SSH.ControlPath != "" && c.SSH.Backend == "native" {
+			return fmt.Errorf("ssh control_path needs the exec backend; the native backend cannot share a ControlMaster")
+		}
+		switch c.SSH.HostKeyPolicy {
+		case "", HostKeyStrict, HostKeyAcceptNew, HostKeyAsk:
+		default:
+			return fmt.Errorf("unknown ssh host key policy %q (want %s, %s or %s)", c.SSH.HostKeyPolicy, HostKeyStrict, HostKeyAcceptNew, HostKeyAsk)
+		}
+	}
+	if c.UI.FrameRate < 0 || c.UI.FrameRate > 120 {
+		return fmt.Errorf("frame rate must be between 1 and 120 (--frame-rate or ui.frame_rate)")
+	}
+	if c.UI.HistoryPageSize < 0 || c.UI.HistoryPageSize > 1000 {
+		return fmt.Errorf("history page size must be between 1 and 1000 (--history-page-size or ui.history_page_size)")
+	}
+	for _, seg := range c.UI.StatusBar {
+		if !slices.Contains(StatusSegments, seg) {
+			return fmt.Errorf("unknown status bar segment %q (want any of %s)", seg, strings.Join(StatusSegments, ", "))
+		}
+	}
+	if c.Tunnel != nil {
+		if c.SSHEnabled() {
+			return fmt.Errorf("ssh and tunnel are mutually exclusive; configure one")
+		}
+		if !strings.Contains(c.Tunnel.Command, "{local_port}") {
+			return fmt.Errorf("tunnel command must contain {local_port} (--tunnel-command or CLAWCHAT_TUNNEL_COMMAND)")
 		}
 	}
 	return nil
 }
 
+// AuthMode returns the configured auth mode, defaulting to token.
+func (c *Config) AuthMode() string {
+	if c.Auth.Mode == "" {
+		return AuthModeToken
+	}
+	return c.Auth.Mode
+}
+
+// FrameRate returns the streaming redraw rate, defaulting to
+// DefaultFrameRate.
+func (c *Config) FrameRate() int {
+	if c.UI.FrameRate == 0 {
+		return DefaultFrameRate
+	}
+	return c.UI.FrameRate
+}
+
+// HistoryPageSize returns how many messages to load at a time, defaulting
+// to DefaultHistoryPageSize.
+func (c *Config) HistoryPageSize() int {
+	if c.UI.HistoryPageSize == 0 {
+		return DefaultHistoryPageSize
+	}
+	return c.UI.HistoryPageSize
+}
+
+// StatusBar returns the status bar segments, defaulting to
+// DefaultStatusBar. An empty result means no status bar.
+func (c *Config) StatusBar() []string {
+	if c.UI.StatusBar == nil {
+		return DefaultStatusBar
+	}
+	return c.UI.StatusBar
+}
+
+// RequestedScopes returns the operator scopes to request in the handshake.
+// operator.approvals is only added when approvals are enabled, so clients
+// that don't answer them keep the handshake they always had. In read-only
+// mode any write, approval or admin scopes are dropped.
+func (c *Config) RequestedScopes() []string {
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"operator.read", "operator.write"}
+		if c.Approvals {
+			scopes = append(scopes, "operator.approvals")
+		}
+	}
+	if !c.ReadOnly {
+		return scopes
+	}
+	var read []string
+	for _, s := range scopes {
+		if strings.HasSuffix(s, ".read") {
+			read = append(read, s)
+		}
+	}
+	if len(read) == 0 {
+		read = []string{"operator.read"}
+	}
+	return read
+}
+
 // SSHEnabled returns true if SSH tunnel is configured.
 func (c *Config) SSHEnabled() bool {
 	return c.SSH != nil && c.SSH.Host != ""
 }
 
+// TunnelEnabled returns true if a command tunnel is configured.
+func (c *Config) TunnelEnabled() bool {
+	return c.Tunnel != nil && c.Tunnel.Command != ""
+}
+
+// RemoteAddr returns the host:port the tunnel forwards to, as seen from the
+// SSH host.
+func (s *SSH) RemoteAddr() string {
+	host := s.RemoteHost
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	port := s.RemotePort
+	if port == 0 {
+		port = 18789
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// LogPath returns the path of the diagnostics log, or "" if logging is
+// disabled with "-".
+func (c *Config) LogPath() string {
+	switch c.LogFile {
+	case "-":
+		return ""
+	case "":
+		return filepath.Join(filepath.Dir(FilePath()), "clawchat.log")
+	}
+	return ExpandTilde(c.LogFile)
+}
+
 // FilePath returns the path to the config file.
 // Always uses ~/.config (XDG convention) regardless of platform.
 func FilePath() string {
@@ -164,6 +504,17 @@ func env(keys ...string) string {
 	return ""
 }
 
+// splitList splits a comma-separated list, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 // ExpandTilde expands a leading ~ to the user's home directory.
 func ExpandTilde(path string) string {
 	if strings.HasPrefix(path, "~/") {
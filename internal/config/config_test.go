@@ -0,0 +1,148 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadArgs runs load with a fresh flag set and no config file, returning
+// the config and the flag set's usage text.
+func loadArgs(t *testing.T, args ...string) (*Config, string) {
+	t.Helper()
+	t.Setenv("CLAWCHAT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	fs := flag.NewFlagSet("clawchat-cli", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := load(fs, args)
+	if err != nil {
+		t.Fatalf("load(%v): %v", args, err)
+	}
+	var usage strings.Builder
+	fs.SetOutput(&usage)
+	fs.PrintDefaults()
+	return cfg, usage.String()
+}
+
+func TestSecretsAreNotFlagDefaults(t *testing.T) {
+	t.Setenv("OPENCLAW_TOKEN", "tok-secret")
+	t.Setenv("OPENCLAW_PASSWORD", "pw-secret")
+	t.Setenv("OPENCLAW_DEVICE_TOKEN", "dev-secret")
+
+	cfg, usage := loadArgs(t)
+	for _, secret := range []string{"tok-secret", "pw-secret", "dev-secret"} {
+		if strings.Contains(usage, secret) {
+			t.Errorf("--help shows %q:\n%s", secret, usage)
+		}
+	}
+	if cfg.Token != "tok-secret" || cfg.Auth.Password != "pw-secret" || cfg.Auth.DeviceToken != "dev-secret" {
+		t.Errorf("environment secrets lost: %+v", cfg)
+	}
+
+	cfg, _ = loadArgs(t, "--password", "from-flag")
+	if cfg.Auth.Password != "from-flag" || cfg.Token != "tok-secret" {
+		t.Errorf("flag did not override: password %q, token %q", cfg.Auth.Password, cfg.Token)
+	}
+}
+
+func TestRequestedScopes(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		scopes    []string
+		approvals bool
+		readOnly  bool
+		want      string
+	}{
+		{name: "default", want: "operator.read,operator.write"},
+		{name: "approvals", approvals: true, want: "operator.read,operator.write,operator.approvals"},
+		{name: "default read-only", readOnly: true, want: "operator.read"},
+		{name: "approvals read-only", approvals: true, readOnly: true, want: "operator.read"},
+		{name: "custom", scopes: []string{"operator.read", "operator.admin"}, want: "operator.read,operator.admin"},
+		{name: "custom read-only", scopes: []string{"operator.read", "operator.write", "operator.approvals", "operator.admin"}, readOnly: true, want: "operator.read"},
+		{name: "no read scope left", scopes: []string{"operator.write"}, readOnly: true, want: "operator.read"},
+	} {
+		c := &Config{Scopes: tt.scopes, Approvals: tt.approvals, ReadOnly: tt.readOnly}
+		if got := strings.Join(c.RequestedScopes(), ","); got != tt.want {
+			t.Errorf("%s: scopes = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadOnlyFlag(t *testing.T) {
+	cfg, _ := loadArgs(t, "--read-only", "--approvals")
+	if !cfg.ReadOnly {
+		t.Fatal("--read-only not applied")
+	}
+	for _, s := range cfg.RequestedScopes() {
+		if s == "operator.write" || s == "operator.approvals" {
+			t.Errorf("--read-only still requests %s", s)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CLAWCHAT_CONFIG", path)
+	file := "gateway_url: ws://file:1\nsession_key: file\nauth:\n  mode: password\nui:\n  history_page_size: 20\n"
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLAWCHAT_SESSION", "env")
+	t.Setenv("CLAWCHAT_HISTORY_PAGE_SIZE", "30")
+
+	fs := flag.NewFlagSet("clawchat-cli", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := load(fs, []string{"--history-page-size", "40", "session", "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GatewayURL != "ws://file:1" || cfg.Auth.Mode != "password" {
+		t.Errorf("file values lost: %+v", cfg)
+	}
+	if cfg.SessionKey != "env" {
+		t.Errorf("session = %q, want the environment's", cfg.SessionKey)
+	}
+	if cfg.UI.HistoryPageSize != 40 {
+		t.Errorf("history page size = %d, want the flag's", cfg.UI.HistoryPageSize)
+	}
+	if args := fs.Args(); strings.Join(args, " ") != "session list" {
+		t.Errorf("remaining args = %v", args)
+	}
+
+	if err := os.WriteFile(path, []byte("gateway_url: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(flag.NewFlagSet("clawchat-cli", flag.ContinueOnError), nil); err == nil {
+		t.Error("malformed config file accepted")
+	}
+	fs = flag.NewFlagSet("clawchat-cli", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := load(fs, []string{"--no-such-flag"}); err == nil {
+		t.Error("unknown flag accepted")
+	}
+}
+
+func TestReloadRereadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CLAWCHAT_CONFIG", path)
+	args := os.Args
+	os.Args = []string{"clawchat-cli", "--session", "from-flag"}
+	t.Cleanup(func() { os.Args = args })
+
+	for _, url := range []string{"ws://first:1", "ws://second:2"} {
+		if err := os.WriteFile(path, []byte("gateway_url: "+url+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// Each call parses the command line afresh; reusing the global
+		// flag set would panic on redefined flags.
+		cfg, err := Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.GatewayURL != url || cfg.SessionKey != "from-flag" {
+			t.Errorf("Reload = %q, %q; want %q with the flag's session", cfg.GatewayURL, cfg.SessionKey, url)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"fmt"
+	"time"
+)
+
+// ApprovalDecision is the operator's answer to an exec approval request.
+type ApprovalDecision string
+
+const (
+	ApproveOnce   ApprovalDecision = "allow-once"
+	ApproveAlways ApprovalDecision = "allow-always"
+	ApprovalDeny  ApprovalDecision = "deny"
+)
+
+// ApprovalRequest is an agent's request to run a command, sent as an
+// "exec.approval.requested" event to clients holding operator.approvals.
+type ApprovalRequest struct {
+	ID         string
+	Command    string
+	Cwd        string
+	Host       string
+	AgentID    string
+	SessionKey string
+	ExpiresAt  time.Time
+}
+
+// ParseApprovalRequest parses a raw "exec.approval.requested" event payload.
+func ParseApprovalRequest(payload map[string]any) ApprovalRequest {
+	req := ApprovalRequest{ID: strField(payload, "id")}
+	if r, ok := payload["request"].(map[string]any); ok {
+		req.Command = strField(r, "command")
+		req.Cwd = strField(r, "cwd")
+		req.Host = strField(r, "host")
+		req.AgentID = strField(r, "agentId")
+		req.SessionKey = strField(r, "sessionKey")
+	}
+	if ms, ok := payload["expiresAtMs"].(float64); ok && ms > 0 {
+		req.ExpiresAt = time.UnixMilli(int64(ms))
+	}
+	return req
+}
+
+// ParseApprovalResolved returns the request ID from an "exec.approval.resolved" payload.
+func ParseApprovalResolved(payload map[string]any) string {
+	return strField(payload, "id")
+}
+
+// ResolveApproval answers a pending exec approval request.
+func (c *Client) ResolveApproval(id string, decision ApprovalDecision) error {
+	_, err := c.Call("exec.approval.resolve", map[string]any{
+		"id":       id,
+		"decision": string(decision),
+	})
+	if err != nil {
+		return fmt.Errorf("exec.approval.resolve: %w", err)
+	}
+	return nil
+}
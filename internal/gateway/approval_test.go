@@ -0,0 +1,62 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseApprovalRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]any
+		want    ApprovalRequest
+	}{
+		{
+			"full",
+			map[string]any{
+				"id": "ap-1",
+				"request": map[string]any{
+					"command":    "rm -rf build",
+					"cwd":        "/srv/app",
+					"host":       "web-1",
+					"agentId":    "ops",
+					"sessionKey": "agent:ops:main",
+				},
+				"expiresAtMs": float64(1700000000000),
+			},
+			ApprovalRequest{
+				ID: "ap-1", Command: "rm -rf build", Cwd: "/srv/app", Host: "web-1",
+				AgentID: "ops", SessionKey: "agent:ops:main", ExpiresAt: time.UnixMilli(1700000000000),
+			},
+		},
+		{"id only", map[string]any{"id": "ap-2"}, ApprovalRequest{ID: "ap-2"}},
+		{"zero expiry", map[string]any{"id": "ap-3", "expiresAtMs": float64(0)}, ApprovalRequest{ID: "ap-3"}},
+		{"malformed request", map[string]any{"id": "ap-4", "request": "ls", "expiresAtMs": "soon"}, ApprovalRequest{ID: "ap-4"}},
+		{"empty", map[string]any{}, ApprovalRequest{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseApprovalRequest(tt.payload)
+			if !got.ExpiresAt.Equal(tt.want.ExpiresAt) {
+				t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, tt.want.ExpiresAt)
+			}
+			got.ExpiresAt, tt.want.ExpiresAt = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("ParseApprovalRequest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultScopesOmitApprovals(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	params := <-g.connect
+	scopes, _ := params["scopes"].([]any)
+	if len(scopes) != 2 || scopes[0] != "operator.read" || scopes[1] != "operator.write" {
+		t.Errorf("scopes = %v, want operator.read, operator.write", scopes)
+	}
+}
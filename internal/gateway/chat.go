@@ -3,19 +3,32 @@ package gateway
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Session holds metadata about a gateway session.
 type Session struct {
-	Key     string
-	Label   string
-	Channel string
-	Model   string
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Channel string `json:"channel"`
+	Model   string `json:"model"`
+
+	// Reported by gateways that track them; empty or zero otherwise.
+	ThinkingLevel string `json:"thinkingLevel"`
+	VerboseLevel  string `json:"verboseLevel"`
+	InputTokens   int    `json:"inputTokens"`
+	OutputTokens  int    `json:"outputTokens"`
+	TotalTokens   int    `json:"totalTokens"`   // tokens in the session's context
+	ContextTokens int    `json:"contextTokens"` // the model's context window
 }
 
 // Message is a chat message.
 type Message struct {
+	ID        string // stable across clients; empty if the gateway does not send one
 	Role      string
 	Content   string
 	Timestamp time.Time
@@ -26,8 +39,11 @@ type ChatEvent struct {
 	RunID      string
 	SessionKey string
 	Seq        int
-	State      string // "delta", "final", "error"
+	State      string // "delta", "final", "aborted", "error"
+	Role       string // of the message: "assistant", or "user" for a prompt sent by any client
+	MessageID  string
 	Content    string // accumulated text
+	Timestamp  time.Time
 	ErrorMsg   string
 }
 
@@ -40,56 +56,103 @@ func (c *Client) ListSessions() ([]Session, error) {
 
 	raw, _ := json.Marshal(payload)
 	var result struct {
-		Sessions []struct {
-			Key     string `json:"key"`
-			Label   string `json:"label"`
-			Channel string `json:"channel"`
-			Model   string `json:"model"`
-		} `json:"sessions"`
+		Sessions []Session `json:"sessions"`
 	}
 	if err := json.Unmarshal(raw, &result); err != nil {
 		return nil, fmt.Errorf("parsing sessions: %w", err)
 	}
+	return result.Sessions, nil
+}
 
-	sessions := make([]Session, len(result.Sessions))
-	for i, s := range result.Sessions {
-		sessions[i] = Session{
-			Key:     s.Key,
-			Label:   s.Label,
-			Channel: s.Channel,
-			Model:   s.Model,
-		}
+// HistoryPage is a run of consecutive messages from a session's history,
+// oldest first.
+type HistoryPage struct {
+	Messages []Message
+	// Cursor fetches the page before this one; "" when this page reaches
+	// the start of the session.
+	Cursor string
+}
+
+// beforePrefix marks a cursor made from the oldest message's timestamp, for
+// gateways that page by time rather than returning a cursor. It may be
+// followed by ":" and the escaped, comma-separated IDs of messages at that
+// millisecond which have already been returned.
+const beforePrefix = "before:"
+
+// beforeCursor pages back from ms, skipping the messages in ids.
+func beforeCursor(ms int64, ids []string) string {
+	cursor := beforePrefix + strconv.FormatInt(ms, 10)
+	if len(ids) == 0 {
+		return cursor
+	}
+	escaped := make([]string, len(ids))
+	for i, id := range ids {
+		escaped[i] = url.QueryEscape(id)
 	}
-	return sessions, nil
+	return cursor + ":" + strings.Join(escaped, ",")
 }
 
-// GetHistory returns recent messages for a session.
-func (c *Client) GetHistory(sessionKey string, limit int) ([]Message, error) {
+// GetHistory returns up to limit messages for a session ending just before
+// cursor, or the most recent ones if cursor is "". Pass the returned page's
+// Cursor to fetch the page before it.
+func (c *Client) GetHistory(sessionKey string, limit int, cursor string) (HistoryPage, error) {
 	if limit == 0 {
 		limit = 50
 	}
-	payload, err := c.Call("chat.history", map[string]any{
+	params := map[string]any{
 		"sessionKey": sessionKey,
 		"limit":      limit,
-	})
+	}
+	var boundary int64 // millisecond a before: cursor pages back from
+	var skip []string  // messages at boundary already returned
+	if rest, ok := strings.CutPrefix(cursor, beforePrefix); ok {
+		ms, ids, _ := strings.Cut(rest, ":")
+		var err error
+		if boundary, err = strconv.ParseInt(ms, 10, 64); err != nil {
+			return HistoryPage{}, fmt.Errorf("chat.history: bad cursor %q", cursor)
+		}
+		for _, id := range strings.Split(ids, ",") {
+			if id == "" {
+				continue
+			}
+			if id, err = url.QueryUnescape(id); err != nil {
+				return HistoryPage{}, fmt.Errorf("chat.history: bad cursor %q", cursor)
+			}
+			skip = append(skip, id)
+		}
+		// Other messages may share the boundary millisecond: ask for it
+		// too, with room for the ones to skip.
+		params["before"] = boundary + 1
+		limit += len(skip)
+		params["limit"] = limit
+	} else if cursor != "" {
+		params["cursor"] = cursor
+	}
+	payload, err := c.Call("chat.history", params)
 	if err != nil {
-		return nil, fmt.Errorf("chat.history: %w", err)
+		return HistoryPage{}, fmt.Errorf("chat.history: %w", err)
 	}
 
 	raw, _ := json.Marshal(payload)
 	var result struct {
 		Messages []struct {
+			ID        string `json:"id"`
 			Role      string `json:"role"`
 			Content   any    `json:"content"`
 			Timestamp any    `json:"timestamp"`
 		} `json:"messages"`
+		NextCursor string `json:"nextCursor"`
+		HasMore    *bool  `json:"hasMore"`
 	}
 	if err := json.Unmarshal(raw, &result); err != nil {
-		return nil, fmt.Errorf("parsing history: %w", err)
+		return HistoryPage{}, fmt.Errorf("parsing history: %w", err)
 	}
 
-	messages := make([]Message, 0, len(result.Messages))
+	page := HistoryPage{Messages: make([]Message, 0, len(result.Messages))}
 	for _, m := range result.Messages {
+		if m.ID != "" && slices.Contains(skip, m.ID) {
+			continue
+		}
 		// Only show user and assistant text messages — skip tool calls, results, system
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
@@ -98,21 +161,42 @@ func (c *Client) GetHistory(sessionKey string, limit int) ([]Message, error) {
 		if content == "" {
 			continue
 		}
-		msg := Message{
-			Role:    m.Role,
-			Content: content,
-		}
-		switch ts := m.Timestamp.(type) {
-		case float64:
-			msg.Timestamp = time.UnixMilli(int64(ts))
-		case string:
-			if t, err := time.Parse(time.RFC3339, ts); err == nil {
-				msg.Timestamp = t
+		page.Messages = append(page.Messages, Message{
+			ID:        m.ID,
+			Role:      m.Role,
+			Content:   content,
+			Timestamp: parseTimestamp(m.Timestamp),
+		})
+	}
+
+	// Prefer the gateway's own cursor. Otherwise a full page may have more
+	// before it, found by the oldest timestamp (tool messages included, as
+	// the gateway counts them against limit). Messages at that timestamp
+	// are skipped next time, as the next page includes it.
+	more := result.NextCursor != "" || len(result.Messages) >= limit
+	if result.HasMore != nil {
+		more = *result.HasMore
+	}
+	switch {
+	case !more:
+	case result.NextCursor != "":
+		page.Cursor = result.NextCursor
+	case len(result.Messages) > 0:
+		if ts := parseTimestamp(result.Messages[0].Timestamp); !ts.IsZero() {
+			ms := ts.UnixMilli()
+			var ids []string
+			if ms == boundary {
+				ids = skip
+			}
+			for _, m := range result.Messages {
+				if m.ID != "" && parseTimestamp(m.Timestamp).UnixMilli() == ms && !slices.Contains(ids, m.ID) {
+					ids = append(ids, m.ID)
+				}
 			}
+			page.Cursor = beforeCursor(ms, ids)
 		}
-		messages = append(messages, msg)
 	}
-	return messages, nil
+	return page, nil
 }
 
 // SendMessage sends a chat message to a session and returns the run ID.
@@ -141,11 +225,30 @@ func ParseChatEvent(payload map[string]any) ChatEvent {
 		ev.Seq = int(seq)
 	}
 	if msg, ok := payload["message"].(map[string]any); ok {
+		ev.Role = strField(msg, "role")
+		ev.MessageID = strField(msg, "id")
 		ev.Content = extractContent(msg["content"])
+		ev.Timestamp = parseTimestamp(msg["timestamp"])
+	}
+	if ev.Role == "" {
+		ev.Role = "assistant"
 	}
 	return ev
 }
 
+// parseTimestamp reads a timestamp sent as Unix milliseconds or RFC 3339.
+func parseTimestamp(v any) time.Time {
+	switch ts := v.(type) {
+	case float64:
+		return time.UnixMilli(int64(ts))
+	case string:
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 // extractContent converts a content field (string or []ContentBlock) to a plain string.
 func extractContent(v any) string {
 	switch c := v.(type) {
@@ -2,9 +2,12 @@
 package gateway
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,9 +23,19 @@ const (
 	StatusConnecting   Status = "connecting"
 	StatusHandshaking  Status = "handshaking"
 	StatusConnected    Status = "connected"
+	StatusReconnecting Status = "reconnecting"
 	StatusError        Status = "error"
 )
 
+// AuthMode selects the credentials sent in the connect request.
+type AuthMode string
+
+const (
+	AuthToken       AuthMode = "token"        // shared gateway token (default)
+	AuthPassword    AuthMode = "password"     // gateway password
+	AuthDeviceToken AuthMode = "device-token" // token previously issued to this device
+)
+
 // EventHandler is called when a gateway event arrives.
 type EventHandler func(event string, payload map[string]any)
 
@@ -31,14 +44,32 @@ type StatusHandler func(Status)
 
 // Options configures a Client.
 type Options struct {
-	URL             string
-	Token           string
-	OnStatus        StatusHandler
-	OnEvent         EventHandler
-	RequestTimeout  time.Duration
-	MaxRetries      int
+	URL            string
+	Token          string
+	AuthMode       AuthMode // defaults to AuthToken
+	Password       string
+	DeviceToken    string
+	Scopes         []string // defaults to operator.read, operator.write
+	OnStatus       StatusHandler
+	OnEvent        EventHandler
+	RequestTimeout time.Duration
+	MaxRetries     int
+
+	// NetDialContext, if set, opens the underlying connection — e.g. through
+	// an SSH tunnel without going via a local port.
+	NetDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// BeforeReconnect, if set, runs before each reconnect attempt — e.g. to
+	// wait for an SSH tunnel to come back. An error skips the attempt.
+	BeforeReconnect func() error
 }
 
+// Reconnect backoff bounds.
+const (
+	reconnectBaseDelay = 500 * time.Millisecond
+	reconnectMaxDelay  = 30 * time.Second
+)
+
 // Client is a Protocol v3 OpenClaw Gateway WebSocket client.
 type Client struct {
 	opts Options
@@ -46,7 +77,12 @@ type Client struct {
 	mu           sync.Mutex
 	conn         *websocket.Conn
 	status       Status
-	lastErr      error // stores the actual handshake/connection error
+	lastErr      error         // stores the actual handshake/connection error
+	clockOffset  time.Duration // gateway clock minus local clock, from connect.challenge
+	offsetKnown  bool
+	reconnecting bool
+	retryAt      time.Time     // when the next reconnect attempt is due
+	latency      time.Duration // round trip of the last answered request
 
 	pendingMu sync.Mutex
 	pending   map[string]chan response
@@ -55,6 +91,10 @@ type Client struct {
 
 	done chan struct{}
 	once sync.Once
+
+	// now and device are swapped out by tests for deterministic signing.
+	now    func() time.Time
+	device deviceSource
 }
 
 type response struct {
@@ -70,41 +110,89 @@ func New(opts Options) *Client {
 	if opts.MaxRetries == 0 {
 		opts.MaxRetries = 10
 	}
+	if opts.AuthMode == "" {
+		opts.AuthMode = AuthToken
+	}
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{"operator.read", "operator.write"}
+	}
 	return &Client{
 		opts:    opts,
 		status:  StatusDisconnected,
 		pending: make(map[string]chan response),
 		done:    make(chan struct{}),
+		now:     time.Now,
+		device:  loadOrCreateDevice,
 	}
 }
 
 // Connect establishes the WebSocket connection and performs the handshake.
 // It returns once the handshake is complete (status = connected) or fails.
+// If an established connection later drops, the client reconnects on its own
+// with exponential backoff, up to MaxRetries attempts.
 func (c *Client) Connect() error {
+	return c.dial()
+}
+
+// dial opens a fresh WebSocket connection and waits for the handshake.
+func (c *Client) dial() error {
+	// The clock offset belongs to one connection's challenge; a challenge
+	// without a timestamp must not sign with the last connection's.
+	c.mu.Lock()
+	c.lastErr = nil
+	c.clockOffset = 0
+	c.offsetKnown = false
+	c.mu.Unlock()
 	c.setStatus(StatusConnecting)
 
 	u, err := url.Parse(c.opts.URL)
 	if err != nil {
 		return fmt.Errorf("invalid gateway URL: %w", err)
 	}
-	q := u.Query()
-	q.Set("token", c.opts.Token)
-	u.RawQuery = q.Encode()
+	if c.opts.AuthMode == AuthToken {
+		q := u.Query()
+		q.Set("token", c.opts.Token)
+		u.RawQuery = q.Encode()
+	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	// Close aborts a dial in progress, so shutdown never waits on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = c.opts.NetDialContext
+	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
 	if err != nil {
-		c.setStatus(StatusError)
-		return fmt.Errorf("websocket dial: %w", err)
+		err = fmt.Errorf("websocket dial: %w", err)
+		c.fail(err)
+		return err
 	}
 
 	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		_ = conn.Close()
+		return fmt.Errorf("client closed during connect")
+	default:
+	}
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
 	c.conn = conn
 	c.mu.Unlock()
 
 	c.setStatus(StatusHandshaking)
 
 	// Start read loop
-	go c.readLoop()
+	go c.readLoop(conn)
 
 	// Wait for connected (handshake driven by readLoop)
 	deadline := time.After(c.opts.RequestTimeout)
@@ -113,20 +201,16 @@ func (c *Client) Connect() error {
 	for {
 		select {
 		case <-deadline:
-			c.Close()
-			return fmt.Errorf("handshake timed out")
+			_ = conn.Close()
+			err := fmt.Errorf("handshake timed out")
+			c.fail(err)
+			return err
 		case <-tick.C:
 			if c.Status() == StatusConnected {
 				return nil
 			}
-			if c.Status() == StatusError {
-				c.mu.Lock()
-				err := c.lastErr
-				c.mu.Unlock()
-				if err != nil {
-					return err
-				}
-				return fmt.Errorf("handshake failed")
+			if err := c.LastError(); err != nil {
+				return err
 			}
 		case <-c.done:
 			return fmt.Errorf("client closed during connect")
@@ -134,6 +218,69 @@ func (c *Client) Connect() error {
 	}
 }
 
+// reconnect re-dials after an established connection drops, backing off
+// exponentially between attempts. It gives up after MaxRetries attempts.
+func (c *Client) reconnect() {
+	c.mu.Lock()
+	if c.reconnecting {
+		c.mu.Unlock()
+		return
+	}
+	c.reconnecting = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.reconnecting = false
+		c.mu.Unlock()
+	}()
+
+	delay := reconnectBaseDelay
+	for attempt := 0; attempt < c.opts.MaxRetries; attempt++ {
+		c.mu.Lock()
+		c.retryAt = time.Now().Add(delay)
+		c.mu.Unlock()
+		c.setStatus(StatusReconnecting)
+		select {
+		case <-time.After(delay):
+		case <-c.done:
+			return
+		}
+		c.mu.Lock()
+		c.retryAt = time.Time{}
+		c.mu.Unlock()
+		delay = min(delay*2, reconnectMaxDelay)
+
+		if c.opts.BeforeReconnect != nil {
+			if err := c.opts.BeforeReconnect(); err != nil {
+				c.mu.Lock()
+				c.lastErr = err
+				c.mu.Unlock()
+				continue
+			}
+		}
+		if err := c.dial(); err == nil {
+			return
+		}
+	}
+	c.setStatus(StatusError)
+}
+
+// fail records why the current connection attempt failed. Outside a
+// reconnect it reports StatusError; during one the status stays on
+// reconnecting, since a UI would take each failed attempt for the end of
+// the connection. reconnect reports StatusError once it gives up.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.lastErr == nil {
+		c.lastErr = err
+	}
+	quiet := c.reconnecting
+	c.mu.Unlock()
+	if !quiet {
+		c.setStatus(StatusError)
+	}
+}
+
 // Close shuts down the connection.
 func (c *Client) Close() {
 	c.once.Do(func() {
@@ -155,6 +302,30 @@ func (c *Client) Status() Status {
 	return c.status
 }
 
+// RetryAt returns when the next reconnect attempt is due, or the zero time
+// if the client is not waiting to reconnect.
+func (c *Client) RetryAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retryAt
+}
+
+// LastError returns why the last connection attempt failed, or nil. While
+// reconnecting it is the error of the previous attempt.
+func (c *Client) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// Latency returns the round-trip time of the last answered request, or 0
+// before the first.
+func (c *Client) Latency() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latency
+}
+
 // Call sends a request and waits for a response.
 func (c *Client) Call(method string, params map[string]any) (map[string]any, error) {
 	id := fmt.Sprintf("cc-%d", c.seq.Add(1))
@@ -170,6 +341,7 @@ func (c *Client) Call(method string, params map[string]any) (map[string]any, err
 	c.pending[id] = ch
 	c.pendingMu.Unlock()
 
+	start := time.Now()
 	if err := c.sendJSON(frame); err != nil {
 		c.pendingMu.Lock()
 		delete(c.pending, id)
@@ -179,6 +351,11 @@ func (c *Client) Call(method string, params map[string]any) (map[string]any, err
 
 	select {
 	case r := <-ch:
+		if r.err == nil {
+			c.mu.Lock()
+			c.latency = time.Since(start)
+			c.mu.Unlock()
+		}
 		return r.payload, r.err
 	case <-time.After(c.opts.RequestTimeout):
 		c.pendingMu.Lock()
@@ -194,13 +371,13 @@ func (c *Client) Call(method string, params map[string]any) (map[string]any, err
 func (c *Client) sendHandshake(nonce string) error {
 	id := fmt.Sprintf("cc-%d", c.seq.Add(1))
 
-	scopes := []string{"operator.read", "operator.write"}
+	scopes := c.opts.Scopes
 
 	// Build device identity — required for the gateway to grant scopes.
 	var deviceField map[string]any
-	dev, devErr := loadOrCreateDevice()
+	dev, devErr := c.device()
 	if devErr == nil {
-		sig, signedAt, signErr := dev.sign(nonce, c.opts.Token, "operator", scopes)
+		sig, signedAt, signErr := dev.sign(nonce, c.signedToken(), "operator", scopes, c.gatewayNow())
 		if signErr == nil {
 			deviceField = map[string]any{
 				"id":        dev.DeviceID,
@@ -215,7 +392,7 @@ func (c *Client) sendHandshake(nonce string) error {
 	params := map[string]any{
 		"role":        "operator",
 		"scopes":      scopes,
-		"auth":        map[string]any{"token": c.opts.Token},
+		"auth":        c.authParams(),
 		"client":      map[string]any{"id": "cli", "version": "dev", "platform": "cli", "mode": "cli"},
 		"minProtocol": 3,
 		"maxProtocol": 3,
@@ -244,67 +421,125 @@ func (c *Client) sendHandshake(nonce string) error {
 	select {
 	case r := <-ch:
 		if r.err != nil {
-			err := fmt.Errorf("handshake rejected: %w", r.err)
-			c.mu.Lock()
-			c.lastErr = err
-			c.mu.Unlock()
-			c.setStatus(StatusError)
+			err := c.diagnoseSkew(fmt.Errorf("handshake rejected: %w", r.err))
+			c.fail(err)
 			return err
 		}
 		if t, _ := r.payload["type"].(string); t != "hello-ok" {
 			err := fmt.Errorf("unexpected handshake response: %v", r.payload)
-			c.mu.Lock()
-			c.lastErr = err
-			c.mu.Unlock()
-			c.setStatus(StatusError)
+			c.fail(err)
 			return err
 		}
 		c.setStatus(StatusConnected)
 		return nil
 	case <-time.After(c.opts.RequestTimeout):
 		err := fmt.Errorf("handshake timed out after %s", c.opts.RequestTimeout)
-		c.mu.Lock()
-		c.lastErr = err
-		c.mu.Unlock()
-		c.setStatus(StatusError)
+		c.fail(err)
 		return err
 	case <-c.done:
 		return fmt.Errorf("client closed during handshake")
 	}
 }
 
-// readLoop reads frames from the WebSocket and dispatches them.
-func (c *Client) readLoop() {
-	defer func() {
-		select {
-		case <-c.done:
-		default:
-			c.setStatus(StatusError)
-			c.rejectAllPending("read loop exited")
-		}
-	}()
+// authParams builds the connect request's auth object for the configured mode.
+func (c *Client) authParams() map[string]any {
+	switch c.opts.AuthMode {
+	case AuthPassword:
+		return map[string]any{"password": c.opts.Password}
+	case AuthDeviceToken:
+		return map[string]any{"deviceToken": c.opts.DeviceToken}
+	default:
+		return map[string]any{"token": c.opts.Token}
+	}
+}
 
-	for {
-		select {
-		case <-c.done:
-			return
-		default:
-		}
+// signedToken returns the credential bound into the device signature.
+// Password auth signs an empty token, matching the gateway's verifier.
+func (c *Client) signedToken() string {
+	switch c.opts.AuthMode {
+	case AuthPassword:
+		return ""
+	case AuthDeviceToken:
+		return c.opts.DeviceToken
+	default:
+		return c.opts.Token
+	}
+}
 
-		c.mu.Lock()
-		conn := c.conn
-		c.mu.Unlock()
-		if conn == nil {
-			return
+// gatewayNow returns the local time corrected by the offset learned from the
+// gateway's connect.challenge, so signedAt is valid even on a drifting clock.
+func (c *Client) gatewayNow() time.Time {
+	c.mu.Lock()
+	offset := c.clockOffset
+	c.mu.Unlock()
+	return c.now().Add(offset)
+}
+
+// skewTolerance is how far the local clock may drift before a rejection that
+// mentions the signature time is reported as clock skew.
+const skewTolerance = 5 * time.Second
+
+// diagnoseSkew rewrites a handshake rejection that was clearly caused by clock
+// skew into an explicit diagnostic. Other errors are returned unchanged.
+//
+// When the challenge carried the gateway's time the signature was already
+// made with corrected time, so only a rejection that names signedAt is put
+// down to the clock; without a correction, time-related wording is enough
+// to suggest it.
+func (c *Client) diagnoseSkew(err error) error {
+	msg := strings.ToLower(err.Error())
+	skewish := false
+	for _, hint := range []string{"signedat", "skew", "clock", "expired", "stale", "timestamp"} {
+		if strings.Contains(msg, hint) {
+			skewish = true
+			break
 		}
+	}
+	if !skewish {
+		return err
+	}
+
+	c.mu.Lock()
+	offset, known := c.clockOffset, c.offsetKnown
+	c.mu.Unlock()
 
+	if !known {
+		return fmt.Errorf("%w (possible clock skew — check that this machine's clock is synchronized)", err)
+	}
+	if !strings.Contains(msg, "signedat") || offset.Abs() < skewTolerance {
+		return err
+	}
+	direction := "behind"
+	if offset < 0 {
+		direction = "ahead of"
+	}
+	secs := int64(offset.Abs().Round(time.Second) / time.Second)
+	return fmt.Errorf("clock skew of %d seconds: local clock is %s the gateway: %w", secs, direction, err)
+}
+
+// readLoop reads frames from one WebSocket connection and dispatches them.
+// When the connection drops after a completed handshake, it starts reconnect.
+func (c *Client) readLoop(conn *websocket.Conn) {
+	for {
 		_, data, err := conn.ReadMessage()
 		if err != nil {
 			select {
 			case <-c.done:
+				return
 			default:
-				c.setStatus(StatusError)
-				c.rejectAllPending(fmt.Sprintf("read error: %v", err))
+			}
+			c.mu.Lock()
+			current := c.conn == conn
+			wasConnected := c.status == StatusConnected
+			c.mu.Unlock()
+			if !current {
+				return // superseded by a newer connection
+			}
+			c.rejectAllPending(fmt.Sprintf("read error: %v", err))
+			if wasConnected {
+				go c.reconnect()
+			} else {
+				c.fail(fmt.Errorf("connection closed during handshake: %w", err))
 			}
 			return
 		}
@@ -332,14 +567,16 @@ func (c *Client) handleEvent(frame map[string]any) {
 
 	if event == "connect.challenge" {
 		nonce, _ := payload["nonce"].(string)
+		if ts, ok := payload["ts"].(float64); ok && ts > 0 {
+			offset := time.UnixMilli(int64(ts)).Sub(c.now())
+			c.mu.Lock()
+			c.clockOffset = offset
+			c.offsetKnown = true
+			c.mu.Unlock()
+		}
 		go func() {
 			if err := c.sendHandshake(nonce); err != nil {
-				c.mu.Lock()
-				if c.lastErr == nil {
-					c.lastErr = err
-				}
-				c.mu.Unlock()
-				c.setStatus(StatusError)
+				c.fail(err)
 			}
 		}()
 		return
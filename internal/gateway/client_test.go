@@ -0,0 +1,592 @@
+package gateway
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeGateway is a minimal in-process gateway that issues a connect.challenge,
+// records the connect params and answers with reject or hello-ok.
+type fakeGateway struct {
+	t         *testing.T
+	srv       *httptest.Server
+	challenge map[string]any
+	reject    string         // non-empty: reject the handshake with this message
+	results   map[string]any // response payloads, or funcs of the params, by method; others get {}
+	connect   chan map[string]any
+
+	mu    sync.Mutex
+	conns []*websocket.Conn
+	calls map[string]map[string]any // params of the last call to each method
+}
+
+func newFakeGateway(t *testing.T, challenge map[string]any) *fakeGateway {
+	t.Helper()
+	g := &fakeGateway{t: t, challenge: challenge, connect: make(chan map[string]any, 4)}
+	g.srv = httptest.NewServer(http.HandlerFunc(g.serve))
+	t.Cleanup(g.srv.Close)
+	return g
+}
+
+// lastParams returns the params of the last call to method.
+func (g *fakeGateway) lastParams(method string) map[string]any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[method]
+}
+
+func (g *fakeGateway) url() string {
+	return "ws" + strings.TrimPrefix(g.srv.URL, "http")
+}
+
+func (g *fakeGateway) serve(w http.ResponseWriter, r *http.Request) {
+	up := websocket.Upgrader{}
+	conn, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	g.mu.Lock()
+	g.conns = append(g.conns, conn)
+	challenge := g.challenge
+	g.mu.Unlock()
+
+	_ = conn.WriteJSON(map[string]any{"type": "event", "event": "connect.challenge", "payload": challenge})
+	for {
+		var frame map[string]any
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		if method, _ := frame["method"].(string); method != "connect" {
+			params, _ := frame["params"].(map[string]any)
+			g.mu.Lock()
+			if g.calls == nil {
+				g.calls = map[string]map[string]any{}
+			}
+			g.calls[method] = params
+			g.mu.Unlock()
+			payload, ok := g.results[method]
+			if !ok {
+				payload = map[string]any{}
+			}
+			if f, ok := payload.(func(map[string]any) any); ok {
+				payload = f(params)
+			}
+			_ = conn.WriteJSON(map[string]any{"type": "res", "id": frame["id"], "ok": true, "payload": payload})
+			continue
+		}
+		params, _ := frame["params"].(map[string]any)
+		g.connect <- params
+		g.mu.Lock()
+		reject := g.reject
+		g.mu.Unlock()
+		if reject != "" {
+			_ = conn.WriteJSON(map[string]any{"type": "res", "id": frame["id"], "ok": false, "error": map[string]any{"message": reject}})
+			continue
+		}
+		_ = conn.WriteJSON(map[string]any{"type": "res", "id": frame["id"], "ok": true, "payload": map[string]any{"type": "hello-ok"}})
+	}
+}
+
+// dropAll severs every open client connection.
+func (g *fakeGateway) dropAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, c := range g.conns {
+		_ = c.Close()
+	}
+	g.conns = nil
+}
+
+func testDevice(t *testing.T) *deviceIdentity {
+	t.Helper()
+	seed := make([]byte, ed25519.SeedSize)
+	return newDeviceIdentity(ed25519.NewKeyFromSeed(seed), time.UnixMilli(0))
+}
+
+func newTestClient(t *testing.T, url string, now func() time.Time) *Client {
+	t.Helper()
+	dev := testDevice(t)
+	c := New(Options{URL: url, Token: "tok", RequestTimeout: 2 * time.Second})
+	c.now = now
+	c.device = func() (*deviceIdentity, error) { return dev, nil }
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestHandshakeSignsWithGatewayClock(t *testing.T) {
+	serverTime := time.UnixMilli(1735689600000)
+	g := newFakeGateway(t, map[string]any{"nonce": "n1", "ts": float64(serverTime.UnixMilli())})
+
+	// Local clock runs 3 minutes behind the gateway.
+	local := serverTime.Add(-3 * time.Minute)
+	c := newTestClient(t, g.url(), func() time.Time { return local })
+
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	params := <-g.connect
+	dev, _ := params["device"].(map[string]any)
+	if dev == nil {
+		t.Fatal("connect params missing device")
+	}
+	signedAt := int64(dev["signedAt"].(float64))
+	if signedAt != serverTime.UnixMilli() {
+		t.Errorf("signedAt = %d, want gateway time %d", signedAt, serverTime.UnixMilli())
+	}
+
+	raw, _ := json.Marshal(params["scopes"])
+	var scopes []string
+	_ = json.Unmarshal(raw, &scopes)
+	p := signedPayload{
+		Version:    payloadV2,
+		DeviceID:   dev["id"].(string),
+		ClientID:   "cli",
+		ClientMode: "cli",
+		Role:       "operator",
+		Scopes:     scopes,
+		SignedAtMs: signedAt,
+		Token:      "tok",
+		Nonce:      "n1",
+	}
+	if err := verifySignature(dev["publicKey"].(string), dev["signature"].(string), p); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestHandshakeReportsClockSkew(t *testing.T) {
+	serverTime := time.UnixMilli(1735689600000)
+	g := newFakeGateway(t, map[string]any{"nonce": "n1", "ts": float64(serverTime.UnixMilli())})
+	g.reject = "device signedAt outside the allowed window"
+
+	local := serverTime.Add(90 * time.Second)
+	c := newTestClient(t, g.url(), func() time.Time { return local })
+
+	err := c.Connect()
+	if err == nil {
+		t.Fatal("Connect succeeded, want rejection")
+	}
+	if !strings.Contains(err.Error(), "clock skew of 90 seconds") {
+		t.Errorf("error = %q, want clock skew diagnostic", err)
+	}
+	if !strings.Contains(err.Error(), "ahead of") {
+		t.Errorf("error = %q, want local clock reported ahead", err)
+	}
+}
+
+func TestHandshakeSkewWordingAfterCorrection(t *testing.T) {
+	serverTime := time.UnixMilli(1735689600000)
+	local := serverTime.Add(90 * time.Second)
+
+	// The signature was made with corrected time, so "expired" is not put
+	// down to the local clock.
+	g := newFakeGateway(t, map[string]any{"nonce": "n1", "ts": float64(serverTime.UnixMilli())})
+	g.reject = "device token expired"
+	c := newTestClient(t, g.url(), func() time.Time { return local })
+	if err := c.Connect(); err == nil || strings.Contains(err.Error(), "skew") {
+		t.Errorf("error = %v, want the rejection without a skew diagnostic", err)
+	}
+
+	// Without the gateway's time it may well be the clock.
+	g = newFakeGateway(t, map[string]any{"nonce": "n1"})
+	g.reject = "device token expired"
+	c = newTestClient(t, g.url(), func() time.Time { return local })
+	if err := c.Connect(); err == nil || !strings.Contains(err.Error(), "possible clock skew") {
+		t.Errorf("error = %v, want a possible clock skew hint", err)
+	}
+}
+
+func TestReconnectDropsStaleClockOffset(t *testing.T) {
+	serverTime := time.UnixMilli(1735689600000)
+	g := newFakeGateway(t, map[string]any{"nonce": "n1", "ts": float64(serverTime.UnixMilli())})
+	local := serverTime.Add(-3 * time.Minute)
+	c := newTestClient(t, g.url(), func() time.Time { return local })
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	<-g.connect
+
+	// The next challenge has no timestamp: sign with the local clock, not
+	// the offset learned from the first.
+	g.mu.Lock()
+	g.challenge = map[string]any{"nonce": "n2"}
+	g.mu.Unlock()
+	g.dropAll()
+
+	select {
+	case params := <-g.connect:
+		dev, _ := params["device"].(map[string]any)
+		if got := int64(dev["signedAt"].(float64)); got != local.UnixMilli() {
+			t.Errorf("signedAt = %d, want local time %d", got, local.UnixMilli())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not reconnect")
+	}
+}
+
+func TestHandshakeRejectionWithoutSkew(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	g.reject = "invalid token"
+
+	c := newTestClient(t, g.url(), time.Now)
+	err := c.Connect()
+	if err == nil {
+		t.Fatal("Connect succeeded, want rejection")
+	}
+	if strings.Contains(err.Error(), "skew") {
+		t.Errorf("error = %q, should not mention clock skew", err)
+	}
+}
+
+func TestHandshakeAuthModes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]any
+	}{
+		{"token", Options{Token: "tok"}, map[string]any{"token": "tok"}},
+		{"password", Options{AuthMode: AuthPassword, Password: "hunter2"}, map[string]any{"password": "hunter2"}},
+		{"device-token", Options{AuthMode: AuthDeviceToken, DeviceToken: "dt-1"}, map[string]any{"deviceToken": "dt-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+			opts := tt.opts
+			opts.URL = g.url()
+			opts.RequestTimeout = 2 * time.Second
+			c := New(opts)
+			dev := testDevice(t)
+			c.device = func() (*deviceIdentity, error) { return dev, nil }
+			t.Cleanup(c.Close)
+
+			if err := c.Connect(); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			params := <-g.connect
+			auth, _ := params["auth"].(map[string]any)
+			if len(auth) != len(tt.want) {
+				t.Fatalf("auth = %v, want %v", auth, tt.want)
+			}
+			for k, v := range tt.want {
+				if auth[k] != v {
+					t.Errorf("auth[%q] = %v, want %v", k, auth[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestClientReconnectsAfterDrop(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+
+	statuses := make(chan Status, 16)
+	var before atomic.Int32
+	c := New(Options{
+		URL:            g.url(),
+		Token:          "tok",
+		RequestTimeout: 2 * time.Second,
+		OnStatus:       func(s Status) { statuses <- s },
+		BeforeReconnect: func() error {
+			before.Add(1)
+			return nil
+		},
+	})
+	dev := testDevice(t)
+	c.device = func() (*deviceIdentity, error) { return dev, nil }
+	t.Cleanup(c.Close)
+
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	<-g.connect
+
+	g.dropAll()
+
+	sawReconnecting := false
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-statuses:
+			if s == StatusReconnecting {
+				sawReconnecting = true
+			}
+			if s == StatusConnected && sawReconnecting {
+				if before.Load() == 0 {
+					t.Error("BeforeReconnect was not called")
+				}
+				if _, err := c.Call("ping", nil); err != nil {
+					t.Errorf("Call after reconnect: %v", err)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("did not reconnect (status %s)", c.Status())
+		}
+	}
+}
+
+func TestReconnectAttemptsDoNotReportError(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+
+	var mu sync.Mutex
+	var statuses []Status
+	c := New(Options{
+		URL:            g.url(),
+		Token:          "tok",
+		RequestTimeout: 2 * time.Second,
+		MaxRetries:     2,
+		OnStatus: func(s Status) {
+			mu.Lock()
+			statuses = append(statuses, s)
+			mu.Unlock()
+		},
+	})
+	dev := testDevice(t)
+	c.device = func() (*deviceIdentity, error) { return dev, nil }
+	t.Cleanup(c.Close)
+
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	<-g.connect
+
+	g.mu.Lock()
+	g.reject = "gateway restarting"
+	g.mu.Unlock()
+	g.dropAll()
+
+	attempts := 0
+	deadline := time.After(5 * time.Second)
+	for attempts < 2 || c.Status() != StatusError {
+		select {
+		case <-g.connect:
+			attempts++
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("did not give up after %d attempts (status %s)", attempts, c.Status())
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Only giving up is an error; each failed attempt goes back to waiting.
+	for i, s := range statuses[:len(statuses)-1] {
+		if s == StatusError {
+			t.Errorf("status %d of %v is error before the retries ran out", i, statuses)
+		}
+	}
+	if err := c.LastError(); err == nil || !strings.Contains(err.Error(), "gateway restarting") {
+		t.Errorf("LastError = %v, want the last attempt's rejection", err)
+	}
+}
+
+func TestListSessionsReportsLevelsAndUsage(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	g.results = map[string]any{"sessions.list": map[string]any{"sessions": []any{
+		map[string]any{
+			"key": "agent:main:main", "model": "claude-opus", "thinkingLevel": "high", "verboseLevel": "on",
+			"inputTokens": 1200, "outputTokens": 300, "totalTokens": 15000, "contextTokens": 200000,
+		},
+		map[string]any{"key": "agent:main:other"},
+	}}}
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if c.Latency() != 0 {
+		t.Errorf("Latency before any request = %v, want 0", c.Latency())
+	}
+
+	sessions, err := c.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	want := Session{
+		Key: "agent:main:main", Model: "claude-opus", ThinkingLevel: "high", VerboseLevel: "on",
+		InputTokens: 1200, OutputTokens: 300, TotalTokens: 15000, ContextTokens: 200000,
+	}
+	if len(sessions) != 2 || sessions[0] != want || sessions[1] != (Session{Key: "agent:main:other"}) {
+		t.Errorf("sessions = %+v", sessions)
+	}
+	if c.Latency() <= 0 {
+		t.Error("Latency not recorded after a request")
+	}
+}
+
+func TestRetryAtCountsDownToReconnect(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	release := make(chan struct{})
+	c := newTestClient(t, g.url(), time.Now)
+	c.opts.BeforeReconnect = func() error { <-release; return nil }
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	<-g.connect
+	if !c.RetryAt().IsZero() {
+		t.Error("RetryAt set while connected")
+	}
+
+	g.dropAll()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.RetryAt().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	at := c.RetryAt()
+	if at.IsZero() || time.Until(at) > reconnectBaseDelay {
+		t.Fatalf("RetryAt = %v, want within %v", at, reconnectBaseDelay)
+	}
+
+	// Once the wait is over the countdown is cleared.
+	for !c.RetryAt().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !c.RetryAt().IsZero() {
+		t.Error("RetryAt not cleared after the backoff")
+	}
+	close(release)
+}
+
+func TestHistoryAndChatEventsCarryMessageIDs(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	g.results = map[string]any{"chat.history": map[string]any{"messages": []any{
+		map[string]any{"id": "m1", "role": "user", "content": "hi", "timestamp": 1700000000000.0},
+		map[string]any{"id": "m2", "role": "toolResult", "content": "skipped"},
+		map[string]any{"id": "m3", "role": "assistant", "content": []any{map[string]any{"type": "text", "text": "hello"}}},
+	}}}
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	page, err := c.GetHistory("agent:main:main", 10, "")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	history := page.Messages
+	if len(history) != 2 || history[0].ID != "m1" || history[1].ID != "m3" || !history[0].Timestamp.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("history = %+v", history)
+	}
+
+	ev := ParseChatEvent(map[string]any{
+		"runId": "r1", "state": "final",
+		"message": map[string]any{"id": "m4", "role": "user", "content": "from the web"},
+	})
+	if ev.Role != "user" || ev.MessageID != "m4" || ev.Content != "from the web" {
+		t.Errorf("user message event = %+v", ev)
+	}
+	if ev := ParseChatEvent(map[string]any{"runId": "r1", "state": "delta"}); ev.Role != "assistant" {
+		t.Errorf("event without a message has role %q, want assistant", ev.Role)
+	}
+}
+
+func TestGetHistoryPages(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	msg := func(id string, ts float64) map[string]any {
+		return map[string]any{"id": id, "role": "user", "content": id, "timestamp": ts}
+	}
+
+	// A gateway cursor is passed back as is.
+	g.results = map[string]any{"chat.history": map[string]any{
+		"messages": []any{msg("m3", 3000), msg("m4", 4000)}, "nextCursor": "c-2",
+	}}
+	page, err := c.GetHistory("s", 2, "")
+	if err != nil || page.Cursor != "c-2" {
+		t.Fatalf("page = %+v, %v; want cursor c-2", page, err)
+	}
+	if _, err := c.GetHistory("s", 2, page.Cursor); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.lastParams("chat.history"); p["cursor"] != "c-2" || p["before"] != nil {
+		t.Errorf("params = %v, want cursor c-2", p)
+	}
+
+	// Without one, a full page pages by the oldest timestamp.
+	g.results = map[string]any{"chat.history": map[string]any{"messages": []any{msg("m3", 3000), msg("m4", 4000)}}}
+	page, _ = c.GetHistory("s", 2, "")
+	if _, err := c.GetHistory("s", 2, page.Cursor); err != nil {
+		t.Fatal(err)
+	}
+	// The boundary millisecond is asked for again, less the message seen.
+	if page.Cursor != "before:3000:m3" {
+		t.Errorf("cursor = %q", page.Cursor)
+	}
+	if p := g.lastParams("chat.history"); p["before"] != 3001.0 || p["limit"] != 3.0 || p["cursor"] != nil {
+		t.Errorf("params = %v, want before 3001, limit 3", p)
+	}
+
+	// A short page, or hasMore false, is the start of the session.
+	g.results = map[string]any{"chat.history": map[string]any{"messages": []any{msg("m1", 1000)}}}
+	if page, _ := c.GetHistory("s", 2, "before:3000"); page.Cursor != "" {
+		t.Errorf("short page has cursor %q", page.Cursor)
+	}
+	g.results = map[string]any{"chat.history": map[string]any{"messages": []any{msg("m1", 1000), msg("m2", 2000)}, "hasMore": false}}
+	if page, _ := c.GetHistory("s", 2, "before:3000"); page.Cursor != "" {
+		t.Errorf("hasMore false page has cursor %q", page.Cursor)
+	}
+
+	// A mangled cursor is an error, not a request for the newest page.
+	if _, err := c.GetHistory("s", 2, "before:soon"); err == nil {
+		t.Error("unparsable cursor accepted")
+	}
+}
+
+func TestGetHistoryPagesAcrossSharedTimestamp(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	// A gateway that pages by time only: the newest limit messages
+	// strictly before "before".
+	history := []map[string]any{
+		{"id": "m1", "role": "user", "content": "one", "timestamp": 1000.0},
+		{"id": "m2", "role": "assistant", "content": "two", "timestamp": 2000.0},
+		{"id": "m3", "role": "user", "content": "three", "timestamp": 2000.0},
+		{"id": "m4", "role": "assistant", "content": "four", "timestamp": 2000.0},
+		{"id": "m5", "role": "user", "content": "five", "timestamp": 3000.0},
+	}
+	g.results = map[string]any{"chat.history": func(p map[string]any) any {
+		var msgs []any
+		for _, m := range history {
+			if before, ok := p["before"].(float64); !ok || m["timestamp"].(float64) < before {
+				msgs = append(msgs, m)
+			}
+		}
+		if limit := int(p["limit"].(float64)); len(msgs) > limit {
+			msgs = msgs[len(msgs)-limit:]
+		}
+		return map[string]any{"messages": msgs}
+	}}
+
+	var got []string
+	cursor := ""
+	for range 10 {
+		page, err := c.GetHistory("s", 2, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, m := range page.Messages {
+			ids = append(ids, m.ID)
+		}
+		got = append(ids, got...)
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+	if want := "m1 m2 m3 m4 m5"; strings.Join(got, " ") != want {
+		t.Errorf("paged %v, want %s once each", got, want)
+	}
+}
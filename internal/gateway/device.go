@@ -13,6 +13,15 @@ import (
 	"time"
 )
 
+// Signature payload versions understood by the gateway.
+const (
+	payloadV2 = "v2"
+	payloadV3 = "v3" // adds platform and device family; provisional layout, verify-only until the gateway defines it
+)
+
+// signPayloadVersion is the payload version used when signing the handshake.
+const signPayloadVersion = payloadV2
+
 // deviceIdentity holds the persistent ed25519 keypair for this CLI installation.
 type deviceIdentity struct {
 	Version    int    `json:"version"`
@@ -22,6 +31,25 @@ type deviceIdentity struct {
 	CreatedAt  int64  `json:"createdAtMs"`
 }
 
+// deviceSource supplies the device identity used to sign the handshake.
+// Tests substitute a fixed key; the client defaults to loadOrCreateDevice.
+type deviceSource func() (*deviceIdentity, error)
+
+// signedPayload holds the fields covered by the device signature.
+type signedPayload struct {
+	Version      string
+	DeviceID     string
+	ClientID     string
+	ClientMode   string
+	Role         string
+	Scopes       []string
+	SignedAtMs   int64
+	Token        string
+	Nonce        string
+	Platform     string // v3 only
+	DeviceFamily string // v3 only
+}
+
 // deviceKeyPath returns the path to the stored device identity file.
 func deviceKeyPath() string {
 	home, _ := os.UserHomeDir()
@@ -48,18 +76,12 @@ func loadOrCreateDevice() (*deviceIdentity, error) {
 	}
 
 	// Generate new keypair
-	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("generating key pair: %w", err)
 	}
 
-	id := &deviceIdentity{
-		Version:    1,
-		DeviceID:   deviceIDFromPubKey(pubKey),
-		PublicKey:  base64URLEncode(pubKey),
-		PrivateKey: base64URLEncode(privKey),
-		CreatedAt:  time.Now().UnixMilli(),
-	}
+	id := newDeviceIdentity(privKey, time.Now())
 
 	// Persist
 	if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
@@ -71,34 +93,103 @@ func loadOrCreateDevice() (*deviceIdentity, error) {
 	return id, nil
 }
 
-// sign signs the challenge nonce with the device private key.
-// Signature payload format matches ClawChat's device-crypto-ed25519.ts:
+// newDeviceIdentity builds an identity around an existing private key.
+func newDeviceIdentity(privKey ed25519.PrivateKey, createdAt time.Time) *deviceIdentity {
+	pubKey := privKey.Public().(ed25519.PublicKey)
+	return &deviceIdentity{
+		Version:    1,
+		DeviceID:   deviceIDFromPubKey(pubKey),
+		PublicKey:  base64URLEncode(pubKey),
+		PrivateKey: base64URLEncode(privKey),
+		CreatedAt:  createdAt.UnixMilli(),
+	}
+}
+
+// sign signs the challenge nonce with the device private key, stamping the
+// payload with signedAt. The payload format matches ClawChat's
+// device-crypto-ed25519.ts:
 //
 //	v2|{deviceId}|{clientId}|{clientMode}|{role}|{scopes}|{signedAtMs}|{token}|{nonce}
-func (id *deviceIdentity) sign(nonce, token, role string, scopes []string) (string, int64, error) {
+func (id *deviceIdentity) sign(nonce, token, role string, scopes []string, signedAt time.Time) (string, int64, error) {
+	p := signedPayload{
+		Version:    signPayloadVersion,
+		DeviceID:   id.DeviceID,
+		ClientID:   "cli",
+		ClientMode: "cli",
+		Role:       role,
+		Scopes:     scopes,
+		SignedAtMs: signedAt.UnixMilli(),
+		Token:      token,
+		Nonce:      nonce,
+	}
+	sig, err := id.signPayload(p)
+	if err != nil {
+		return "", 0, err
+	}
+	return sig, p.SignedAtMs, nil
+}
+
+// signPayload signs an arbitrary payload and returns the base64url signature.
+func (id *deviceIdentity) signPayload(p signedPayload) (string, error) {
 	privBytes, err := base64URLDecode(id.PrivateKey)
 	if err != nil {
-		return "", 0, fmt.Errorf("decoding private key: %w", err)
+		return "", fmt.Errorf("decoding private key: %w", err)
+	}
+	if len(privBytes) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("decoding private key: invalid length %d", len(privBytes))
+	}
+	msg, err := p.encode()
+	if err != nil {
+		return "", err
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(privBytes), []byte(msg))
+	return base64URLEncode(sig), nil
+}
+
+// verifySignature checks a base64url signature against a base64url public key.
+func verifySignature(publicKey, signature string, p signedPayload) error {
+	pubBytes, err := base64URLDecode(publicKey)
+	if err != nil || len(pubBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	sigBytes, err := base64URLDecode(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	msg, err := p.encode()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pubBytes), []byte(msg), sigBytes) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// encode renders the pipe-delimited string that is actually signed.
+//
+//	v2|{deviceId}|{clientId}|{clientMode}|{role}|{scopes}|{signedAtMs}|{token}|{nonce}
+//	v3|{deviceId}|{clientId}|{clientMode}|{role}|{scopes}|{signedAtMs}|{token}|{nonce}|{platform}|{deviceFamily}
+func (p signedPayload) encode() (string, error) {
+	fields := []string{
+		p.Version,
+		p.DeviceID,
+		p.ClientID,
+		p.ClientMode,
+		p.Role,
+		strings.Join(p.Scopes, ","),
+		fmt.Sprintf("%d", p.SignedAtMs),
+		p.Token,
+		p.Nonce,
+	}
+	switch p.Version {
+	case payloadV2:
+	case payloadV3:
+		fields = append(fields, p.Platform, p.DeviceFamily)
+	default:
+		return "", fmt.Errorf("unsupported signature payload version %q", p.Version)
 	}
-	privKey := ed25519.PrivateKey(privBytes)
-
-	signedAtMs := time.Now().UnixMilli()
-	scopesStr := strings.Join(scopes, ",")
-
-	payload := strings.Join([]string{
-		"v2",
-		id.DeviceID,
-		"cli",     // clientId
-		"cli",     // clientMode
-		role,
-		scopesStr,
-		fmt.Sprintf("%d", signedAtMs),
-		token,
-		nonce,
-	}, "|")
-
-	sig := ed25519.Sign(privKey, []byte(payload))
-	return base64URLEncode(sig), signedAtMs, nil
+	return strings.Join(fields, "|"), nil
 }
 
 // deviceIDFromPubKey computes the device ID as a hex SHA-256 of the public key.
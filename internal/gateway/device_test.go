@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// signatureVectors mirrors testdata/device_signatures.json. The vectors are
+// a regression snapshot: they were produced by this package, not taken from
+// the TypeScript implementation, so they catch changes to the payload or
+// signature but do not by themselves prove compatibility with the gateway.
+// The v2 payloads follow the layout documented on sign; the v3 layout is
+// provisional until the gateway publishes one.
+type signatureVectors struct {
+	PrivateKeySeed string `json:"privateKeySeed"` // hex, RFC 8032 test 1
+	DeviceID       string `json:"deviceId"`
+	PublicKey      string `json:"publicKey"`
+	Vectors        []struct {
+		Version      string   `json:"version"`
+		ClientID     string   `json:"clientId"`
+		ClientMode   string   `json:"clientMode"`
+		Role         string   `json:"role"`
+		Scopes       []string `json:"scopes"`
+		SignedAtMs   int64    `json:"signedAtMs"`
+		Token        string   `json:"token"`
+		Nonce        string   `json:"nonce"`
+		Platform     string   `json:"platform"`
+		DeviceFamily string   `json:"deviceFamily"`
+		Payload      string   `json:"payload"`
+		Signature    string   `json:"signature"`
+	} `json:"vectors"`
+}
+
+func loadSignatureVectors(t *testing.T) (*signatureVectors, *deviceIdentity) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/device_signatures.json")
+	if err != nil {
+		t.Fatalf("reading vectors: %v", err)
+	}
+	var sv signatureVectors
+	if err := json.Unmarshal(data, &sv); err != nil {
+		t.Fatalf("parsing vectors: %v", err)
+	}
+	seed, err := hex.DecodeString(sv.PrivateKeySeed)
+	if err != nil {
+		t.Fatalf("decoding seed: %v", err)
+	}
+	id := newDeviceIdentity(ed25519.NewKeyFromSeed(seed), time.UnixMilli(0))
+	if id.DeviceID != sv.DeviceID {
+		t.Fatalf("device ID = %s, want %s", id.DeviceID, sv.DeviceID)
+	}
+	if id.PublicKey != sv.PublicKey {
+		t.Fatalf("public key = %s, want %s", id.PublicKey, sv.PublicKey)
+	}
+	return &sv, id
+}
+
+func TestSignatureSnapshots(t *testing.T) {
+	sv, id := loadSignatureVectors(t)
+	for _, v := range sv.Vectors {
+		t.Run(v.Version+"/"+v.Nonce, func(t *testing.T) {
+			p := signedPayload{
+				Version:      v.Version,
+				DeviceID:     id.DeviceID,
+				ClientID:     v.ClientID,
+				ClientMode:   v.ClientMode,
+				Role:         v.Role,
+				Scopes:       v.Scopes,
+				SignedAtMs:   v.SignedAtMs,
+				Token:        v.Token,
+				Nonce:        v.Nonce,
+				Platform:     v.Platform,
+				DeviceFamily: v.DeviceFamily,
+			}
+			msg, err := p.encode()
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if msg != v.Payload {
+				t.Errorf("payload:\n got  %s\n want %s", msg, v.Payload)
+			}
+			sig, err := id.signPayload(p)
+			if err != nil {
+				t.Fatalf("signPayload: %v", err)
+			}
+			if sig != v.Signature {
+				t.Errorf("signature:\n got  %s\n want %s", sig, v.Signature)
+			}
+			if err := verifySignature(sv.PublicKey, v.Signature, p); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignUsesInjectedClock(t *testing.T) {
+	sv, id := loadSignatureVectors(t)
+	v := sv.Vectors[0]
+
+	sig, signedAt, err := id.sign(v.Nonce, v.Token, v.Role, v.Scopes, time.UnixMilli(v.SignedAtMs))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if signedAt != v.SignedAtMs {
+		t.Errorf("signedAt = %d, want %d", signedAt, v.SignedAtMs)
+	}
+	if sig != v.Signature {
+		t.Errorf("signature = %s, want %s", sig, v.Signature)
+	}
+}
+
+func TestVerifySignatureRejectsTampering(t *testing.T) {
+	sv, id := loadSignatureVectors(t)
+	v := sv.Vectors[3] // v3
+	p := signedPayload{
+		Version:      v.Version,
+		DeviceID:     id.DeviceID,
+		ClientID:     v.ClientID,
+		ClientMode:   v.ClientMode,
+		Role:         v.Role,
+		Scopes:       v.Scopes,
+		SignedAtMs:   v.SignedAtMs,
+		Token:        v.Token,
+		Nonce:        v.Nonce,
+		Platform:     v.Platform,
+		DeviceFamily: v.DeviceFamily,
+	}
+
+	downgraded := p
+	downgraded.Version = payloadV2
+	if err := verifySignature(sv.PublicKey, v.Signature, downgraded); err == nil {
+		t.Error("v3 signature verified as v2")
+	}
+
+	tampered := p
+	tampered.Platform = "windows"
+	if err := verifySignature(sv.PublicKey, v.Signature, tampered); err == nil {
+		t.Error("signature verified with altered platform")
+	}
+
+	unknown := p
+	unknown.Version = "v9"
+	if err := verifySignature(sv.PublicKey, v.Signature, unknown); err == nil {
+		t.Error("unknown payload version accepted")
+	}
+}
@@ -5,6 +5,7 @@ package gateway
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func getTestConfig(t *testing.T) (gatewayURL, token string) {
@@ -30,7 +31,7 @@ func TestDeviceIdentity(t *testing.T) {
 	t.Logf("device ID: %s", dev.DeviceID)
 	t.Logf("public key: %s", dev.PublicKey[:16]+"...")
 
-	sig, signedAt, err := dev.sign("testnonce", token, "operator", []string{"operator.read", "operator.write"})
+	sig, signedAt, err := dev.sign("testnonce", token, "operator", []string{"operator.read", "operator.write"}, time.Now())
 	if err != nil {
 		t.Fatalf("sign: %v", err)
 	}
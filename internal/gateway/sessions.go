@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NewSession describes a session to create. Empty fields take the
+// gateway's defaults.
+type NewSession struct {
+	Agent string // agent ID the session belongs to
+	Model string
+	Label string
+}
+
+// CreateSession creates a session and returns it.
+func (c *Client) CreateSession(ns NewSession) (Session, error) {
+	params := map[string]any{}
+	for name, v := range map[string]string{"agentId": ns.Agent, "model": ns.Model, "label": ns.Label} {
+		if v != "" {
+			params[name] = v
+		}
+	}
+	payload, err := c.Call("sessions.create", params)
+	if err != nil {
+		return Session{}, fmt.Errorf("sessions.create: %w", err)
+	}
+
+	// Gateways answer with the new session, or just its key.
+	raw, _ := json.Marshal(payload)
+	var result struct {
+		Key     string   `json:"key"`
+		Session *Session `json:"session"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return Session{}, fmt.Errorf("parsing session: %w", err)
+	}
+	if result.Session != nil && result.Session.Key != "" {
+		return *result.Session, nil
+	}
+	if result.Key == "" {
+		return Session{}, fmt.Errorf("sessions.create: no session key in response")
+	}
+	return Session{Key: result.Key, Label: ns.Label, Model: ns.Model}, nil
+}
+
+// RenameSession sets a session's label; an empty label clears it.
+func (c *Client) RenameSession(key, label string) error {
+	if _, err := c.Call("sessions.patch", map[string]any{"key": key, "label": label}); err != nil {
+		return fmt.Errorf("sessions.patch: %w", err)
+	}
+	return nil
+}
+
+// ResetSession clears a session's history and context, keeping the session.
+func (c *Client) ResetSession(key string) error {
+	if _, err := c.Call("sessions.reset", map[string]any{"key": key}); err != nil {
+		return fmt.Errorf("sessions.reset: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession removes a session and its history.
+func (c *Client) DeleteSession(key string) error {
+	if _, err := c.Call("sessions.delete", map[string]any{"key": key}); err != nil {
+		return fmt.Errorf("sessions.delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionManagement(t *testing.T) {
+	g := newFakeGateway(t, map[string]any{"nonce": "n1"})
+	c := newTestClient(t, g.url(), time.Now)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	g.results = map[string]any{"sessions.create": map[string]any{
+		"session": map[string]any{"key": "agent:ops:s1", "label": "deploys", "model": "claude-opus"},
+	}}
+	s, err := c.CreateSession(NewSession{Agent: "ops", Model: "claude-opus", Label: "deploys"})
+	if err != nil || s != (Session{Key: "agent:ops:s1", Label: "deploys", Model: "claude-opus"}) {
+		t.Fatalf("CreateSession = %+v, %v", s, err)
+	}
+	if p := g.lastParams("sessions.create"); p["agentId"] != "ops" || p["model"] != "claude-opus" || p["label"] != "deploys" {
+		t.Errorf("create params = %v", p)
+	}
+
+	// A bare key is enough; unset fields are not sent.
+	g.results = map[string]any{"sessions.create": map[string]any{"key": "agent:main:s2"}}
+	if s, err := c.CreateSession(NewSession{}); err != nil || s.Key != "agent:main:s2" {
+		t.Errorf("CreateSession = %+v, %v", s, err)
+	}
+	if p := g.lastParams("sessions.create"); len(p) != 0 {
+		t.Errorf("create params = %v, want none", p)
+	}
+	g.results = nil
+	if _, err := c.CreateSession(NewSession{}); err == nil {
+		t.Error("response without a key accepted")
+	}
+
+	if err := c.RenameSession("agent:main:s2", "scratch"); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.lastParams("sessions.patch"); p["key"] != "agent:main:s2" || p["label"] != "scratch" {
+		t.Errorf("patch params = %v", p)
+	}
+	for method, call := range map[string]func(string) error{
+		"sessions.reset":  c.ResetSession,
+		"sessions.delete": c.DeleteSession,
+	} {
+		if err := call("agent:main:s2"); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if p := g.lastParams(method); p["key"] != "agent:main:s2" {
+			t.Errorf("%s params = %v", method, p)
+		}
+	}
+}
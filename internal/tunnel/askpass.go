@@ -0,0 +1,168 @@
+package tunnel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// askpassSockEnv tells a re-executed clawchat-cli that it is running as
+// OpenSSH's SSH_ASKPASS helper and where to forward the question.
+const askpassSockEnv = "CLAWCHAT_ASKPASS_SOCK"
+
+// IsAskpass reports whether the process was started by ssh as an askpass helper.
+func IsAskpass() bool {
+	return os.Getenv(askpassSockEnv) != ""
+}
+
+// RunAskpass answers an ssh prompt by relaying it to the TUI over the askpass
+// socket, printing the answer for ssh to read. It returns the exit code.
+func RunAskpass(args []string) int {
+	question := strings.Join(args, " ")
+	answer, err := askpassRequest(os.Getenv(askpassSockEnv), question)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clawchat-cli askpass: %v\n", err)
+		return 1
+	}
+	fmt.Println(answer)
+	return 0
+}
+
+type askpassQuestion struct {
+	Prompt string `json:"prompt"`
+}
+
+type askpassAnswer struct {
+	Answer string `json:"answer"`
+	Error  string `json:"error,omitempty"`
+}
+
+func askpassRequest(sock, question string) (string, error) {
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(askpassQuestion{Prompt: question}); err != nil {
+		return "", err
+	}
+	var a askpassAnswer
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&a); err != nil {
+		return "", err
+	}
+	if a.Error != "" {
+		return "", fmt.Errorf("%s", a.Error)
+	}
+	return a.Answer, nil
+}
+
+// askpassServer relays ssh's askpass questions to the tunnel's prompt funcs.
+type askpassServer struct {
+	dir     string
+	ln      net.Listener
+	opts    Options
+	pending atomic.Int32 // questions waiting for the user
+}
+
+func startAskpass(opts Options) (*askpassServer, error) {
+	dir, err := os.MkdirTemp("", "clawchat-askpass-")
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", filepath.Join(dir, "sock"))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	s := &askpassServer{dir: dir, ln: ln, opts: opts}
+	go s.serve()
+	return s, nil
+}
+
+// env returns the environment that makes ssh use this server for prompts.
+func (s *askpassServer) env() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return append(os.Environ(),
+		"SSH_ASKPASS="+exe,
+		"SSH_ASKPASS_REQUIRE=force",
+		"DISPLAY="+displayOr(":0"),
+		askpassSockEnv+"="+s.ln.Addr().String(),
+	), nil
+}
+
+func (s *askpassServer) close() {
+	_ = s.ln.Close()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *askpassServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *askpassServer) handle(conn net.Conn) {
+	defer conn.Close()
+	var q askpassQuestion
+	if err := json.NewDecoder(conn).Decode(&q); err != nil {
+		return
+	}
+	s.pending.Add(1)
+	answer, err := s.answer(q.Prompt)
+	s.pending.Add(-1)
+	a := askpassAnswer{Answer: answer}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	_ = json.NewEncoder(conn).Encode(a)
+}
+
+var (
+	askpassHostRe        = regexp.MustCompile(`host '([^']+)'`)
+	askpassFingerprintRe = regexp.MustCompile(`(?m)(\S+) key fingerprint is (\S+?)\.?$`)
+)
+
+// answer maps an OpenSSH prompt onto the matching prompt func: new host key
+// confirmations go to ConfirmHostKey, everything else to Prompt.
+func (s *askpassServer) answer(prompt string) (string, error) {
+	if strings.Contains(prompt, "continue connecting") {
+		if s.opts.ConfirmHostKey == nil {
+			return "no", nil
+		}
+		host, fingerprint := "unknown host", ""
+		if m := askpassHostRe.FindStringSubmatch(prompt); m != nil {
+			host = m[1]
+		}
+		if m := askpassFingerprintRe.FindStringSubmatch(prompt); m != nil {
+			fingerprint = m[1] + " " + m[2]
+		}
+		if s.opts.ConfirmHostKey(host, fingerprint) {
+			return "yes", nil
+		}
+		return "no", nil
+	}
+	if s.opts.Prompt == nil {
+		return "", fmt.Errorf("no prompt available")
+	}
+	return s.opts.Prompt(strings.TrimSpace(prompt), false)
+}
+
+func displayOr(def string) string {
+	if v := os.Getenv("DISPLAY"); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,154 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// defaultReadyTimeout bounds how long a command provider may take to come up.
+const defaultReadyTimeout = 30 * time.Second
+
+// commandProvider runs an arbitrary forwarding command, such as
+// kubectl port-forward or cloudflared access tcp, built from a template in
+// which {local_port} is replaced by the tunnel's local port.
+type commandProvider struct {
+	name    string
+	args    []string // template, already split into words
+	ready   *regexp.Regexp
+	timeout time.Duration
+}
+
+// StartCommand establishes a tunnel by running the configured command.
+// It blocks until the command is ready to carry connections or fails.
+func StartCommand(cfg *config.Tunnel, opts Options) (*Tunnel, error) {
+	p, err := newCommandProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return StartProvider(p, opts)
+}
+
+// CommandPath checks the tunnel command and returns the path of the program
+// it runs, or an error if the template is invalid or the program is not
+// installed.
+func CommandPath(cfg *config.Tunnel) (string, error) {
+	p, err := newCommandProvider(cfg)
+	if err != nil {
+		return "", err
+	}
+	return exec.LookPath(p.args[0])
+}
+
+func newCommandProvider(cfg *config.Tunnel) (*commandProvider, error) {
+	args, err := splitCommand(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("tunnel command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("tunnel command is empty")
+	}
+	if !strings.Contains(cfg.Command, "{local_port}") {
+		return nil, fmt.Errorf("tunnel command must contain {local_port}")
+	}
+	p := &commandProvider{
+		name:    filepath.Base(args[0]),
+		args:    args,
+		timeout: cfg.ReadyTimeout,
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultReadyTimeout
+	}
+	if cfg.ReadyPattern != "" {
+		if p.ready, err = regexp.Compile(cfg.ReadyPattern); err != nil {
+			return nil, fmt.Errorf("tunnel ready_pattern: %w", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *commandProvider) Name() string    { return p.name }
+func (p *commandProvider) InProcess() bool { return false }
+func (p *commandProvider) Close()          {}
+
+// Connect runs the command for localPort and waits until its output matches
+// the ready pattern or, without one, until the port accepts connections.
+func (p *commandProvider) Connect(ctx context.Context, localPort int, diag *Diagnostics) (Transport, error) {
+	args := expandTemplate(p.args, localPort)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	return startProcess(p.name, cmd, readiness{port: localPort, pattern: p.ready, timeout: p.timeout}, diag)
+}
+
+// expandTemplate substitutes the tunnel's placeholders in each word.
+func expandTemplate(args []string, localPort int) []string {
+	r := strings.NewReplacer("{local_port}", strconv.Itoa(localPort))
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = r.Replace(a)
+	}
+	return out
+}
+
+// splitCommand splits a command line into words the way a POSIX shell would
+// for simple commands: whitespace separates words, single quotes preserve
+// everything literally, and double quotes and backslashes escape. Pipes,
+// redirects and variables are not supported; the command runs without a
+// shell so killing it leaves nothing behind.
+func splitCommand(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,146 @@
+package tunnel
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// TestHelperForwarder is not a real test: the command provider tests run the
+// test binary as their forwarding command. It listens on the port given after
+// "--", announces itself like kubectl port-forward and echoes connections.
+func TestHelperForwarder(t *testing.T) {
+	if os.Getenv("CLAWCHAT_TEST_FORWARDER") != "1" {
+		t.Skip("helper process")
+	}
+	port := os.Args[len(os.Args)-1]
+	ln, err := net.Listen("tcp", "127.0.0.1:"+port)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Forwarding from 127.0.0.1:%s -> 18789\n", port)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			os.Exit(1)
+		}
+		go func() {
+			defer conn.Close()
+			_, _ = io.Copy(conn, conn)
+		}()
+	}
+}
+
+func forwarderCommand(t *testing.T) string {
+	t.Helper()
+	t.Setenv("CLAWCHAT_TEST_FORWARDER", "1")
+	return fmt.Sprintf("'%s' -test.run=TestHelperForwarder -- {local_port}", os.Args[0])
+}
+
+func startCommand(t *testing.T, cfg *config.Tunnel, opts Options) (*Tunnel, error) {
+	t.Helper()
+	p, err := newCommandProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tun := newTunnel(p, opts)
+	tun.baseDelay = 10 * time.Millisecond
+	tun.maxDelay = 50 * time.Millisecond
+	return tun.start()
+}
+
+func TestCommandTunnel(t *testing.T) {
+	for name, pattern := range map[string]string{"port": "", "pattern": "^Forwarding from"} {
+		t.Run(name, func(t *testing.T) {
+			states := make(chan State, 8)
+			tun, err := startCommand(t, &config.Tunnel{Command: forwarderCommand(t), ReadyPattern: pattern},
+				Options{OnState: func(s State) { states <- s }})
+			if err != nil {
+				t.Fatalf("startCommand: %v", err)
+			}
+			defer tun.Stop()
+			if !strings.HasPrefix(tun.Name(), "tunnel.test") {
+				t.Errorf("Name = %q, want the command's basename", tun.Name())
+			}
+
+			conn, err := net.Dial("tcp", strings.TrimPrefix(tun.GatewayURL(), "ws://"))
+			if err != nil {
+				t.Fatalf("dial local port: %v", err)
+			}
+			assertEcho(t, conn)
+
+			// Killing the forwarder triggers a restart on the same port.
+			tun.currentTransport().(*processTransport).cmd.Process.Kill()
+			for _, want := range []State{StateReconnecting, StateUp} {
+				select {
+				case got := <-states:
+					if got != want {
+						t.Fatalf("state = %s, want %s", got, want)
+					}
+				case <-time.After(10 * time.Second):
+					t.Fatalf("timed out waiting for state %s", want)
+				}
+			}
+			conn, err = net.Dial("tcp", strings.TrimPrefix(tun.GatewayURL(), "ws://"))
+			if err != nil {
+				t.Fatalf("dial after restart: %v", err)
+			}
+			assertEcho(t, conn)
+		})
+	}
+}
+
+func TestCommandTunnelFailsWithOutput(t *testing.T) {
+	_, err := startCommand(t, &config.Tunnel{Command: "sh -c 'echo no route to cluster >&2; exit 1' {local_port}"}, Options{})
+	if err == nil {
+		t.Fatal("startCommand succeeded with a failing command")
+	}
+	if !strings.Contains(err.Error(), "no route to cluster") {
+		t.Errorf("error = %v, want the command's output", err)
+	}
+}
+
+func TestNewCommandProviderValidates(t *testing.T) {
+	for _, cmd := range []string{"", "kubectl port-forward svc/gateway 18789", "ssh 'unterminated {local_port}"} {
+		if _, err := newCommandProvider(&config.Tunnel{Command: cmd}); err == nil {
+			t.Errorf("newCommandProvider(%q) succeeded", cmd)
+		}
+	}
+	if _, err := newCommandProvider(&config.Tunnel{Command: "x {local_port}", ReadyPattern: "("}); err == nil {
+		t.Error("invalid ready_pattern accepted")
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		"kubectl port-forward -n oc svc/gateway {local_port}:18789":                       {"kubectl", "port-forward", "-n", "oc", "svc/gateway", "{local_port}:18789"},
+		`cloudflared access tcp --hostname "gw.example.com" --url 127.0.0.1:{local_port}`: {"cloudflared", "access", "tcp", "--hostname", "gw.example.com", "--url", "127.0.0.1:{local_port}"},
+		`'/opt/my tools/fwd' a\ b "c 'd'" ''`:                                             {"/opt/my tools/fwd", "a b", "c 'd'", ""},
+	}
+	for in, want := range tests {
+		got, err := splitCommand(in)
+		if err != nil {
+			t.Errorf("splitCommand(%q): %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	got := expandTemplate([]string{"fwd", "{local_port}:18789", "--port={local_port}"}, 4000)
+	want := []string{"fwd", "4000:18789", "--port=4000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandTemplate = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// controlCheckInterval is how often an attached forward checks that its
+// ControlMaster is still alive.
+const controlCheckInterval = 10 * time.Second
+
+// controlTimeout bounds a single ssh -O command. Control commands run
+// detached from the tunnel's context so that -O cancel still runs on stop.
+const controlTimeout = 10 * time.Second
+
+// controlProvider attaches the forward to an existing OpenSSH ControlMaster
+// with ssh -O forward, so no new SSH handshake is needed. Without a live
+// master it falls back to a connection of its own, like the exec backend.
+type controlProvider struct {
+	*execProvider
+	path       string // ControlPath, tokens like %h left for ssh to expand
+	checkEvery time.Duration
+}
+
+func newControlProvider(cfg *config.SSH, opts Options) *controlProvider {
+	return &controlProvider{
+		execProvider: newExecProvider(cfg, opts),
+		path:         config.ExpandTilde(cfg.ControlPath),
+		checkEvery:   controlCheckInterval,
+	}
+}
+
+// Connect adds the forward to the master, or starts ssh with -L if no master
+// is running.
+func (p *controlProvider) Connect(ctx context.Context, localPort int, diag *Diagnostics) (Transport, error) {
+	if err := p.control("check"); err != nil {
+		diag.Logf("ssh: no control master at %s, connecting directly", p.path)
+		return p.execProvider.Connect(ctx, localPort, diag)
+	}
+	spec := forwardSpec(localPort, p.cfg.RemoteAddr())
+	if err := p.control("forward", "-L", spec); err != nil {
+		return nil, fmt.Errorf("adding forward to control master: %w", err)
+	}
+	diag.Logf("ssh: attached forward %s to control master at %s", spec, p.path)
+	tr := &controlTransport{p: p, spec: spec, diag: diag, done: make(chan struct{}), stop: make(chan struct{})}
+	go tr.watch()
+	return tr, nil
+}
+
+// control runs an ssh -O command against the control socket.
+func (p *controlProvider) control(op string, args ...string) error {
+	argv := []string{"-S", p.path, "-O", op}
+	argv = append(argv, args...)
+	argv = append(argv, sshDestArgs(p.cfg)...)
+	ctx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, p.bin, argv...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("ssh -O %s: %w: %s", op, err, msg)
+		}
+		return fmt.Errorf("ssh -O %s: %w", op, err)
+	}
+	return nil
+}
+
+// controlTransport is a forward held by a ControlMaster. The master owns the
+// listener; the transport only watches the master and cancels the forward
+// when closed, leaving the master itself running.
+type controlTransport struct {
+	p    *controlProvider
+	spec string
+	diag *Diagnostics
+	done chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+func (tr *controlTransport) Done() <-chan struct{} { return tr.done }
+
+func (tr *controlTransport) Close() {
+	tr.once.Do(func() {
+		close(tr.stop)
+		<-tr.done
+		_ = tr.p.control("cancel", "-L", tr.spec)
+	})
+}
+
+// watch closes done when the master goes away or the transport is closed.
+func (tr *controlTransport) watch() {
+	defer close(tr.done)
+	tick := time.NewTicker(tr.p.checkEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tr.stop:
+			return
+		case <-tick.C:
+			if err := tr.p.control("check"); err != nil {
+				tr.diag.Logf("ssh: control master went away: %v", err)
+				return
+			}
+		}
+	}
+}
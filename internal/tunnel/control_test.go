@@ -0,0 +1,134 @@
+package tunnel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// fakeSSH writes an ssh stand-in that logs its arguments and answers
+// -O check according to whether the master file exists. Anything else that
+// is not a control command fails as if the host were unreachable.
+func fakeSSH(t *testing.T) (bin, master, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	bin = filepath.Join(dir, "ssh")
+	master = filepath.Join(dir, "master")
+	log = filepath.Join(dir, "log")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %q
+case "$*" in
+*"-O check"*) [ -e %q ] || { echo "Control socket connect(%s): No such file or directory" >&2; exit 255; } ;;
+*"-O "*) ;;
+*) echo "ssh: connect to host gw port 22: Connection refused" >&2; exit 255 ;;
+esac
+`, log, master, master)
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(master, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return bin, master, log
+}
+
+func startControl(t *testing.T, bin string, cfg *config.SSH, opts Options) (*Tunnel, error) {
+	t.Helper()
+	p := newControlProvider(cfg, opts)
+	p.bin = bin
+	p.checkEvery = 20 * time.Millisecond
+	tun := newTunnel(p, opts)
+	tun.baseDelay = 10 * time.Millisecond
+	tun.maxDelay = 50 * time.Millisecond
+	return tun.start()
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestControlMasterForwardAndCancel(t *testing.T) {
+	bin, _, log := fakeSSH(t)
+	cfg := &config.SSH{Host: "gw", Port: 2222, User: "me", ControlPath: "/tmp/cm-%r@%h:%p"}
+
+	tun, err := startControl(t, bin, cfg, Options{})
+	if err != nil {
+		t.Fatalf("startControl: %v", err)
+	}
+	if _, ok := tun.currentTransport().(*controlTransport); !ok {
+		t.Fatalf("transport = %T, want *controlTransport", tun.currentTransport())
+	}
+	tun.Stop()
+
+	spec := fmt.Sprintf("-L %d:127.0.0.1:18789", tun.LocalPort)
+	got := readLog(t, log)
+	for _, want := range []string{
+		"-S /tmp/cm-%r@%h:%p -O forward " + spec + " -p 2222 me@gw",
+		"-S /tmp/cm-%r@%h:%p -O cancel " + spec + " -p 2222 me@gw",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ssh calls missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "-O exit") || strings.Contains(got, "-O stop") {
+		t.Errorf("master was shut down:\n%s", got)
+	}
+}
+
+func TestControlMasterLossRestarts(t *testing.T) {
+	bin, master, _ := fakeSSH(t)
+	states := make(chan State, 8)
+	cfg := &config.SSH{Host: "gw", ControlPath: "/tmp/cm"}
+	tun, err := startControl(t, bin, cfg, Options{OnState: func(s State) { states <- s }})
+	if err != nil {
+		t.Fatalf("startControl: %v", err)
+	}
+	defer tun.Stop()
+
+	if err := os.Remove(master); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-states:
+		if s != StateReconnecting {
+			t.Fatalf("state = %s, want %s", s, StateReconnecting)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("master loss not noticed")
+	}
+
+	// The fallback connection fails; once the master is back the forward
+	// is attached to it again.
+	if err := os.WriteFile(master, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := tun.WaitUp(5 * time.Second); err != nil {
+		t.Fatalf("WaitUp: %v", err)
+	}
+	if _, ok := tun.currentTransport().(*controlTransport); !ok {
+		t.Errorf("transport = %T, want *controlTransport", tun.currentTransport())
+	}
+}
+
+func TestControlMasterMissingFallsBack(t *testing.T) {
+	bin, master, log := fakeSSH(t)
+	_ = os.Remove(master)
+	cfg := &config.SSH{Host: "gw", ControlPath: "/tmp/cm"}
+	_, err := startControl(t, bin, cfg, Options{})
+	if err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Fatalf("err = %v, want the fallback connection's error", err)
+	}
+	if !strings.Contains(readLog(t, log), "-N ") {
+		t.Errorf("no fallback ssh -N connection attempted:\n%s", readLog(t, log))
+	}
+}
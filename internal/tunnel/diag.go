@@ -0,0 +1,168 @@
+package tunnel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDiagLines is how many lines a Diagnostics keeps when no capacity is
+// given.
+const defaultDiagLines = 500
+
+// Line is one diagnostic line logged by a tunnel.
+type Line struct {
+	Time time.Time
+	Text string
+}
+
+// Diagnostics keeps the most recent lines a tunnel has logged — forwarder
+// output, connection errors, restarts — in a fixed-size ring, and copies
+// each line to an optional log writer. It outlives individual transports and
+// may be shared across tunnels, so a failed start can still be inspected.
+// It is safe for concurrent use.
+type Diagnostics struct {
+	mu      sync.Mutex
+	lines   []Line
+	next    int  // ring index of the next write
+	full    bool // lines has wrapped
+	problem string
+	out     io.Writer
+}
+
+// NewDiagnostics returns a Diagnostics holding up to capacity lines (500 if
+// capacity <= 0) that also writes every line to out, if non-nil.
+func NewDiagnostics(capacity int, out io.Writer) *Diagnostics {
+	if capacity <= 0 {
+		capacity = defaultDiagLines
+	}
+	return &Diagnostics{lines: make([]Line, capacity), out: out}
+}
+
+// Logf records a line.
+func (d *Diagnostics) Logf(format string, args ...any) {
+	d.add(fmt.Sprintf(format, args...))
+}
+
+// Lines returns the retained lines, oldest first.
+func (d *Diagnostics) Lines() []Line {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.full {
+		return append([]Line(nil), d.lines[:d.next]...)
+	}
+	return append(append([]Line(nil), d.lines[d.next:]...), d.lines[:d.next]...)
+}
+
+// Problem returns the actionable explanation of the most recent line that
+// matched a known failure, or "" if none has.
+func (d *Diagnostics) Problem() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.problem
+}
+
+// Writer returns a writer that records each line written to it, prefixed
+// with source. Partial lines are held until their newline arrives.
+func (d *Diagnostics) Writer(source string) io.Writer {
+	return &lineWriter{d: d, prefix: source + ": "}
+}
+
+func (d *Diagnostics) add(text string) {
+	text = strings.TrimRight(text, "\r\n")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lines[d.next] = Line{Time: now, Text: text}
+	d.next++
+	if d.next == len(d.lines) {
+		d.next, d.full = 0, true
+	}
+	if hint := explain(text); hint != "" {
+		d.problem = hint
+	}
+	if d.out != nil {
+		fmt.Fprintf(d.out, "%s tunnel: %s\n", now.Format(time.RFC3339), text)
+	}
+}
+
+// lineWriter splits a byte stream into Diagnostics lines.
+type lineWriter struct {
+	d       *Diagnostics
+	prefix  string
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimRight(string(w.partial[:i]), "\r"); strings.TrimSpace(line) != "" {
+			w.d.add(w.prefix + line)
+		}
+		w.partial = w.partial[i+1:]
+	}
+	// A runaway line without newlines is flushed rather than buffered forever.
+	if len(w.partial) > maxOutput {
+		w.d.add(w.prefix + string(w.partial))
+		w.partial = nil
+	}
+	return len(b), nil
+}
+
+// Problem explanations returned by Explain.
+const (
+	HintAuth        = "SSH authentication failed — check ssh.user and ssh.key_path, or load your key into ssh-agent"
+	HintHostKey     = "the SSH host key does not match known_hosts — verify the new key, then remove the stale entry with ssh-keygen -R"
+	HintUnreachable = "cannot reach the SSH host — check ssh.host and ssh.port, your network, and that sshd is running"
+	HintPortInUse   = "the local port is already in use — another forward or program holds it"
+	HintForward     = "the SSH host cannot reach the gateway — check ssh.remote_host and ssh.remote_port, and that the gateway is running"
+	HintKeepalive   = "the connection stopped answering keepalives — the network dropped or the host went away"
+)
+
+// problems maps output from OpenSSH, x/crypto/ssh and the net package to
+// hints. Order matters: the first match wins.
+var problems = []struct {
+	re   *regexp.Regexp
+	hint string
+}{
+	{regexp.MustCompile(`(?i)host key.*(changed|mismatch)|REMOTE HOST IDENTIFICATION HAS CHANGED|host key verification failed`), HintHostKey},
+	{regexp.MustCompile(`(?i)permission denied|unable to authenticate|no supported methods remain|no SSH credentials|too many authentication failures`), HintAuth},
+	{regexp.MustCompile(`(?i)address already in use|cannot listen to port`), HintPortInUse},
+	{regexp.MustCompile(`(?i)open failed|connect failed|administratively prohibited|forwarding to .*refused|port forwarding failed`), HintForward},
+	{regexp.MustCompile(`(?i)connection refused|no route to host|network is unreachable|could not resolve hostname|no such host|connection timed out|i/o timeout`), HintUnreachable},
+	{regexp.MustCompile(`(?i)keepalive|timeout, server .* not responding`), HintKeepalive},
+}
+
+// explain classifies one line of output, returning "" if it is not a known
+// failure.
+func explain(text string) string {
+	for _, p := range problems {
+		if p.re.MatchString(text) {
+			return p.hint
+		}
+	}
+	return ""
+}
+
+// Explain returns an actionable explanation of a tunnel error, or "" if it
+// is not a recognized failure.
+func Explain(err error) string {
+	if err == nil {
+		return ""
+	}
+	return explain(err.Error())
+}
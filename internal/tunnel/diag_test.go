@@ -0,0 +1,99 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+func lineTexts(d *Diagnostics) []string {
+	var out []string
+	for _, l := range d.Lines() {
+		out = append(out, l.Text)
+	}
+	return out
+}
+
+func TestDiagnosticsRing(t *testing.T) {
+	var log strings.Builder
+	d := NewDiagnostics(3, &log)
+	for i := 1; i <= 5; i++ {
+		d.Logf("line %d", i)
+	}
+	if got, want := strings.Join(lineTexts(d), ","), "line 3,line 4,line 5"; got != want {
+		t.Errorf("lines = %s, want %s", got, want)
+	}
+	if n := strings.Count(log.String(), " tunnel: line "); n != 5 {
+		t.Errorf("log has %d lines, want all 5:\n%s", n, log.String())
+	}
+}
+
+func TestDiagnosticsWriterSplitsLines(t *testing.T) {
+	d := NewDiagnostics(0, nil)
+	w := d.Writer("ssh")
+	fmt.Fprint(w, "Warning: Permanently added 'gw' to the list")
+	if len(d.Lines()) != 0 {
+		t.Fatal("partial line recorded before its newline")
+	}
+	fmt.Fprint(w, " of known hosts.\r\n\nchannel 3: open failed: connect failed: Connection refused\n")
+	want := []string{
+		"ssh: Warning: Permanently added 'gw' to the list of known hosts.",
+		"ssh: channel 3: open failed: connect failed: Connection refused",
+	}
+	if got := lineTexts(d); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if d.Problem() != HintForward {
+		t.Errorf("Problem = %q, want %q", d.Problem(), HintForward)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	tests := map[string]string{
+		"me@gw: Permission denied (publickey).":                                             HintAuth,
+		"ssh gw (gw:22): ssh: handshake failed: ssh: unable to authenticate":                HintAuth,
+		"ssh: connect to host gw port 22: Connection refused":                               HintUnreachable,
+		"ssh gw (gw:22): dial tcp: lookup gw: no such host":                                 HintUnreachable,
+		"ssh: Could not resolve hostname gw: Name or service not known":                     HintUnreachable,
+		"bind [127.0.0.1]:4000: Address already in use":                                     HintPortInUse,
+		"forwarding to 127.0.0.1:18789: ssh: rejected: connect failed (Connection refused)": HintForward,
+		"host key for gw:22 has changed (possible man-in-the-middle attack)":                HintHostKey,
+		"Host key verification failed.":                                                     HintHostKey,
+		"Timeout, server gw not responding.":                                                HintKeepalive,
+		"Authenticated to gw ([10.0.0.5]:22).":                                              "",
+	}
+	for text, want := range tests {
+		if got := Explain(errors.New(text)); got != want {
+			t.Errorf("Explain(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if Explain(nil) != "" {
+		t.Error("Explain(nil) is not empty")
+	}
+}
+
+func TestProcessOutputStreamsToDiagnostics(t *testing.T) {
+	d := NewDiagnostics(0, nil)
+	_, err := StartProvider(mustCommandProvider(t, "sh -c 'echo bind: cannot listen to port: {local_port} >&2; exit 1'"), Options{Diagnostics: d})
+	if err == nil {
+		t.Fatal("StartProvider succeeded with a failing command")
+	}
+	if Explain(err) != HintPortInUse || d.Problem() != HintPortInUse {
+		t.Errorf("Explain = %q, Problem = %q, want %q", Explain(err), d.Problem(), HintPortInUse)
+	}
+	if got := strings.Join(lineTexts(d), "\n"); !strings.Contains(got, "sh: bind: cannot listen to port") {
+		t.Errorf("command output not in diagnostics:\n%s", got)
+	}
+}
+
+func mustCommandProvider(t *testing.T, cmd string) Provider {
+	t.Helper()
+	p, err := newCommandProvider(&config.Tunnel{Command: cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
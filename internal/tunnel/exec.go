@@ -0,0 +1,136 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// execProvider forwards through the system ssh binary with -L.
+type execProvider struct {
+	cfg  *config.SSH
+	opts Options
+	bin  string // ssh binary, replaced in tests
+
+	mu      sync.Mutex
+	askpass *askpassServer // relays ssh prompts to Options, started on demand
+}
+
+func newExecProvider(cfg *config.SSH, opts Options) *execProvider {
+	return &execProvider{cfg: cfg, opts: opts, bin: "ssh"}
+}
+
+func (p *execProvider) Name() string    { return "SSH" }
+func (p *execProvider) InProcess() bool { return false }
+
+// Connect spawns ssh with -L on localPort and waits for the port to accept
+// connections.
+func (p *execProvider) Connect(ctx context.Context, localPort int, diag *Diagnostics) (Transport, error) {
+	cfg := p.cfg
+	keyPath := config.ExpandTilde(cfg.KeyPath)
+
+	// Prompts go through SSH_ASKPASS back to the TUI when it can answer them;
+	// otherwise ssh runs in batch mode and fails instead of blocking.
+	interactive := p.opts.Prompt != nil || p.opts.ConfirmHostKey != nil
+	strict := map[string]string{
+		config.HostKeyStrict:    "yes",
+		config.HostKeyAcceptNew: "accept-new",
+		config.HostKeyAsk:       "ask",
+	}[hostKeyPolicy(cfg)]
+	if strict == "ask" && p.opts.ConfirmHostKey == nil {
+		strict = "yes"
+	}
+
+	args := []string{
+		"-N",
+		"-o", "StrictHostKeyChecking=" + strict,
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-L", forwardSpec(localPort, cfg.RemoteAddr()),
+	}
+	if !interactive {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	if keyPath != "" {
+		args = append(args, "-i", keyPath)
+	}
+	if len(cfg.JumpHosts) > 0 {
+		args = append(args, "-J", strings.Join(cfg.JumpHosts, ","))
+	}
+	args = append(args, sshDestArgs(cfg)...)
+
+	cmd := exec.CommandContext(ctx, p.bin, args...)
+	if interactive {
+		env, err := p.askpassEnv()
+		if err != nil {
+			return nil, fmt.Errorf("starting askpass: %w", err)
+		}
+		cmd.Env = env
+	}
+
+	// The clock stops while ssh waits on a prompt, so a passphrase typed
+	// slowly doesn't time the start out.
+	return startProcess("ssh", cmd, readiness{port: localPort, timeout: 15 * time.Second, waiting: p.prompting}, diag)
+}
+
+// prompting reports whether ssh is waiting for the user to answer a prompt.
+func (p *execProvider) prompting() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.askpass != nil && p.askpass.pending.Load() > 0
+}
+
+// Close stops the askpass relay.
+func (p *execProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.askpass != nil {
+		p.askpass.close()
+		p.askpass = nil
+	}
+}
+
+// askpassEnv starts the askpass relay once per provider and returns the
+// environment that points ssh at it.
+func (p *execProvider) askpassEnv() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.askpass == nil {
+		s, err := startAskpass(p.opts)
+		if err != nil {
+			return nil, err
+		}
+		p.askpass = s
+	}
+	return p.askpass.env()
+}
+
+// sshDestArgs returns the port option and [user@]host destination. Port and
+// user are only passed when set explicitly, so values from a Host alias in
+// ~/.ssh/config still apply.
+func sshDestArgs(cfg *config.SSH) []string {
+	var args []string
+	if cfg.Port != 0 {
+		args = append(args, "-p", fmt.Sprintf("%d", cfg.Port))
+	}
+	dest := cfg.Host
+	if cfg.User != "" {
+		dest = cfg.User + "@" + cfg.Host
+	}
+	return append(args, dest)
+}
+
+// forwardSpec builds the -L argument, bracketing IPv6 remote hosts.
+func forwardSpec(localPort int, remote string) string {
+	host, port, _ := net.SplitHostPort(remote)
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%d:%s:%s", localPort, host, port)
+}
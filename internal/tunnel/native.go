@@ -0,0 +1,429 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// keepaliveInterval matches the exec backend's ServerAliveInterval.
+const keepaliveInterval = 30 * time.Second
+
+// nativeEnv holds the host-specific inputs of the native backend so tests can
+// point it at a throwaway known_hosts file instead of the user's.
+type nativeEnv struct {
+	knownHosts  []string // known_hosts files; new keys are appended to the first
+	agentSocket string   // ssh-agent socket, empty to skip the agent
+	lookup      sshConfigLookup
+	defaultKeys []string // identity files tried when no key_path is set
+	timeout     time.Duration
+}
+
+func defaultNativeEnv() nativeEnv {
+	home, _ := os.UserHomeDir()
+	sshDir := filepath.Join(home, ".ssh")
+	return nativeEnv{
+		knownHosts: []string{
+			filepath.Join(sshDir, "known_hosts"),
+			"/etc/ssh/ssh_known_hosts",
+		},
+		agentSocket: os.Getenv("SSH_AUTH_SOCK"),
+		lookup:      userSSHConfig,
+		defaultKeys: []string{
+			filepath.Join(sshDir, "id_ed25519"),
+			filepath.Join(sshDir, "id_ecdsa"),
+			filepath.Join(sshDir, "id_rsa"),
+		},
+		timeout: 15 * time.Second,
+	}
+}
+
+// nativeProvider forwards over an in-process SSH client, hopping through any
+// jump hosts first.
+type nativeProvider struct {
+	cfg    *config.SSH
+	opts   Options
+	env    nativeEnv
+	remote string // host:port dialed from the SSH server
+
+	mu      sync.Mutex
+	signers map[string]ssh.Signer // decrypted keys, reused across restarts
+}
+
+func newNativeProvider(cfg *config.SSH, opts Options) *nativeProvider {
+	return &nativeProvider{cfg: cfg, opts: opts, env: defaultNativeEnv(), remote: cfg.RemoteAddr()}
+}
+
+func (p *nativeProvider) Name() string    { return "SSH" }
+func (p *nativeProvider) InProcess() bool { return true }
+func (p *nativeProvider) Close()          {}
+
+// Connect dials the SSH server, hopping through any jump hosts, and checks
+// that the far side accepts the forward.
+func (p *nativeProvider) Connect(ctx context.Context, _ int, diag *Diagnostics) (Transport, error) {
+	env := p.env
+
+	hops, err := resolveHops(p.cfg, env.lookup)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, hostKeyAlgos, err := p.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*ssh.Client
+	for i, h := range hops {
+		auths, closeAgent, err := p.authMethods(h)
+		if err != nil {
+			closeChain(chain)
+			return nil, fmt.Errorf("ssh %s: %w", h.Alias, err)
+		}
+		clientCfg := &ssh.ClientConfig{
+			User:              h.User,
+			Auth:              auths,
+			HostKeyCallback:   hostKeys,
+			HostKeyAlgorithms: hostKeyAlgos(h.addr()),
+			Timeout:           env.timeout,
+		}
+		var conn net.Conn
+		if i == 0 {
+			d := net.Dialer{Timeout: env.timeout}
+			conn, err = d.DialContext(ctx, "tcp", h.addr())
+		} else {
+			conn, err = chain[i-1].DialContext(ctx, "tcp", h.addr())
+		}
+		var client *ssh.Client
+		if err == nil {
+			client, err = handshake(ctx, conn, h.addr(), clientCfg)
+		}
+		closeAgent()
+		if err != nil {
+			closeChain(chain)
+			return nil, fmt.Errorf("ssh %s (%s): %w", h.Alias, h.addr(), err)
+		}
+		chain = append(chain, client)
+	}
+	client := chain[len(chain)-1]
+
+	// Equivalent of ExitOnForwardFailure: make sure the far side accepts the
+	// forward before reporting the tunnel as ready.
+	probe, err := client.Dial("tcp", p.remote)
+	if err != nil {
+		closeChain(chain)
+		return nil, fmt.Errorf("forwarding to %s: %w", p.remote, err)
+	}
+	_ = probe.Close()
+
+	tr := &nativeTransport{client: client, chain: chain, remote: p.remote, done: make(chan struct{})}
+	go func() {
+		_ = client.Wait()
+		close(tr.done)
+	}()
+	go keepalive(client, tr.done, keepaliveInterval, diag)
+	return tr, nil
+}
+
+// nativeTransport is one SSH connection chain carrying the forward.
+type nativeTransport struct {
+	client *ssh.Client   // final hop; carries the forward
+	chain  []*ssh.Client // every hop, jump hosts first
+	remote string
+	done   chan struct{}
+}
+
+func (tr *nativeTransport) Done() <-chan struct{} { return tr.done }
+
+func (tr *nativeTransport) Close() {
+	closeChain(tr.chain)
+	<-tr.done
+}
+
+// DialContext opens a connection to the gateway from the SSH server.
+func (tr *nativeTransport) DialContext(ctx context.Context) (net.Conn, error) {
+	return tr.client.DialContext(ctx, "tcp", tr.remote)
+}
+
+// handshake runs the SSH handshake over conn, which is either a TCP
+// connection or, for jump hosts, a channel through the previous hop as
+// OpenSSH does for each ProxyJump hop. Cancelling ctx aborts it.
+func handshake(ctx context.Context, conn net.Conn, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if !stop() || err != nil {
+		_ = conn.Close()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// closeChain closes a hop chain from the innermost connection outwards.
+func closeChain(chain []*ssh.Client) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		_ = chain[i].Close()
+	}
+}
+
+// keepalive sends OpenSSH-style keepalive requests until done is closed.
+// A keepalive that fails or goes unanswered for a full interval closes the
+// client, so the monitor notices a dead link instead of hanging on it.
+func keepalive(client *ssh.Client, done <-chan struct{}, every time.Duration, diag *Diagnostics) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-done:
+			return
+		}
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+		select {
+		case err := <-reply:
+			if err == nil {
+				continue
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			diag.Logf("ssh: keepalive failed: %v", err)
+		case <-time.After(every):
+			diag.Logf("ssh: keepalive timed out after %s", every)
+		}
+		_ = client.Close()
+		return
+	}
+}
+
+// authMethods collects ssh-agent and key file credentials for a hop, plus
+// password and keyboard-interactive auth when a prompt is available. The
+// returned function closes the agent connection once the handshake is done.
+func (p *nativeProvider) authMethods(h hop) ([]ssh.AuthMethod, func(), error) {
+	var (
+		methods []ssh.AuthMethod
+		closer  = func() {}
+		env     = p.env
+		prompt  = p.opts.Prompt
+	)
+
+	if env.agentSocket != "" {
+		if conn, err := net.Dial("unix", env.agentSocket); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			closer = func() { _ = conn.Close() }
+		}
+	}
+
+	keys := env.defaultKeys
+	if h.KeyPath != "" {
+		keys = []string{h.KeyPath}
+	}
+	var (
+		signers []ssh.Signer
+		keyErr  error
+	)
+	for _, path := range keys {
+		signer, err := p.loadKey(path)
+		if err != nil {
+			if h.KeyPath != "" || !errors.Is(err, os.ErrNotExist) {
+				keyErr = err
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if prompt != nil {
+		who := h.User + "@" + h.Alias
+		methods = append(methods,
+			ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i, q := range questions {
+					if instruction != "" && i == 0 {
+						q = instruction + "\n" + q
+					}
+					a, err := prompt(who+": "+q, echos[i])
+					if err != nil {
+						return nil, err
+					}
+					answers[i] = a
+				}
+				return answers, nil
+			}),
+			ssh.PasswordCallback(func() (string, error) {
+				return prompt(who+"'s password:", false)
+			}),
+		)
+	}
+
+	if len(methods) == 0 {
+		closer()
+		if keyErr != nil {
+			return nil, nil, keyErr
+		}
+		return nil, nil, fmt.Errorf("no SSH credentials: start ssh-agent or set ssh.key_path")
+	}
+	return methods, closer, nil
+}
+
+// loadKey parses a private key file, asking for its passphrase through the
+// prompt if it is encrypted. Decrypted keys are cached so restarts do not
+// prompt again.
+func (p *nativeProvider) loadKey(path string) (ssh.Signer, error) {
+	p.mu.Lock()
+	cached := p.signers[path]
+	p.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if p.opts.Prompt == nil {
+			return nil, fmt.Errorf("key %s is passphrase-protected; load it into ssh-agent", path)
+		}
+		pass, perr := p.opts.Prompt("Passphrase for key "+path+":", false)
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(pass))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing key %s: %w", path, err)
+	}
+
+	p.mu.Lock()
+	if p.signers == nil {
+		p.signers = make(map[string]ssh.Signer)
+	}
+	p.signers[path] = signer
+	p.mu.Unlock()
+	return signer, nil
+}
+
+// hostKeyCallback verifies host keys against known_hosts. What happens to a
+// host seen for the first time depends on the policy: strict rejects it,
+// accept-new trusts and records it, and ask records it only if the user
+// confirms the fingerprint. A changed key for a known host is always rejected.
+//
+// The second result gives the host key algorithms to offer a host: as
+// OpenSSH does, those of the keys known_hosts has for it, so a server with
+// several host keys presents one we can check rather than one that looks
+// like a changed key. It is nil for unknown hosts.
+func (p *nativeProvider) hostKeyCallback() (ssh.HostKeyCallback, func(addr string) []string, error) {
+	files := p.env.knownHosts
+	var existing []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+
+	check := func(string, net.Addr, ssh.PublicKey) error {
+		return &knownhosts.KeyError{}
+	}
+	if len(existing) > 0 {
+		cb, err := knownhosts.New(existing...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading known_hosts: %w", err)
+		}
+		check = cb
+	}
+
+	algorithms := func(addr string) []string {
+		// A key that matches nothing makes knownhosts list every key it
+		// has for addr.
+		var keyErr *knownhosts.KeyError
+		if !errors.As(check(addr, &net.TCPAddr{}, unknownKey{}), &keyErr) {
+			return nil
+		}
+		var algos []string
+		for _, k := range keyErr.Want {
+			algos = append(algos, keyAlgorithms(k.Key.Type())...)
+		}
+		slices.Sort(algos)
+		return algos
+	}
+
+	policy := hostKeyPolicy(p.cfg)
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key for %s has changed (possible man-in-the-middle attack): %w", hostname, err)
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+		switch policy {
+		case config.HostKeyStrict:
+			return fmt.Errorf("host key for %s (%s %s) is not in known_hosts", hostname, key.Type(), fingerprint)
+		case config.HostKeyAsk:
+			if p.opts.ConfirmHostKey == nil || !p.opts.ConfirmHostKey(hostname, key.Type()+" "+fingerprint) {
+				return fmt.Errorf("host key for %s (%s %s) was not accepted", hostname, key.Type(), fingerprint)
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		return appendKnownHost(files[0], hostname, key)
+	}, algorithms, nil
+}
+
+// keyAlgorithms returns the signature algorithms a host key of the given
+// type can be used with.
+func keyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// unknownKey is a public key that is in no known_hosts file.
+type unknownKey struct{}
+
+func (unknownKey) Type() string                        { return "" }
+func (unknownKey) Marshal() []byte                     { return nil }
+func (unknownKey) Verify([]byte, *ssh.Signature) error { return errors.New("unknownKey cannot verify") }
+
+// appendKnownHost records a newly trusted host key.
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
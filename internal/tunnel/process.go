@@ -0,0 +1,156 @@
+package tunnel
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxOutput caps how much of a forwarder's output is kept for error messages
+// and ready-pattern matching.
+const maxOutput = 8 << 10
+
+// readiness decides when an external forwarder can carry connections.
+type readiness struct {
+	port    int            // local port that must accept a TCP connection
+	pattern *regexp.Regexp // output to wait for instead of probing the port
+	timeout time.Duration
+
+	// waiting, if set, reports whether the process is blocked on the user,
+	// e.g. an askpass prompt. That time does not count towards timeout.
+	waiting func() bool
+}
+
+// processTransport is an external program that listens on the local port and
+// forwards to the gateway: the system ssh binary or a command provider.
+type processTransport struct {
+	cmd    *exec.Cmd
+	exited chan struct{} // closed once cmd has been reaped
+	output *outputLog
+}
+
+// startProcess runs cmd and waits until it is ready. Its stderr, and its
+// stdout when waiting for a ready pattern, are streamed into diag for the
+// process's whole life. name prefixes those lines and errors, e.g. "ssh".
+func startProcess(name string, cmd *exec.Cmd, ready readiness, diag *Diagnostics) (*processTransport, error) {
+	out := newOutputLog(ready.pattern)
+	w := io.MultiWriter(out, diag.Writer(name))
+	cmd.Stderr = w
+	if ready.pattern != nil {
+		cmd.Stdout = w
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", name, err)
+	}
+
+	// Reap the process as soon as it exits so both startup and the monitor
+	// can observe it.
+	p := &processTransport{cmd: cmd, exited: make(chan struct{}), output: out}
+	go func() {
+		_ = cmd.Wait()
+		close(p.exited)
+	}()
+
+	if err := p.waitReady(ready); err != nil {
+		p.Close()
+		if msg := out.String(); msg != "" {
+			return nil, fmt.Errorf("%s did not become ready: %w (%s output: %s)", name, err, name, msg)
+		}
+		return nil, fmt.Errorf("%s did not become ready: %w", name, err)
+	}
+	return p, nil
+}
+
+// Done is closed when the process exits.
+func (p *processTransport) Done() <-chan struct{} {
+	return p.exited
+}
+
+// Close kills the process and waits for it to be reaped.
+func (p *processTransport) Close() {
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	<-p.exited
+}
+
+// waitReady blocks until the output matches the ready pattern or, without
+// one, until the local port accepts a TCP connection.
+func (p *processTransport) waitReady(r readiness) error {
+	if r.pattern != nil {
+		select {
+		case <-p.output.matched:
+			return nil
+		case <-p.exited:
+			return fmt.Errorf("process exited prematurely")
+		case <-time.After(r.timeout):
+			return fmt.Errorf("timed out after %s waiting for output matching %q", r.timeout, r.pattern)
+		}
+	}
+
+	addr := fmt.Sprintf("127.0.0.1:%d", r.port)
+	var elapsed time.Duration
+	last := time.Now()
+	for {
+		now := time.Now()
+		if r.waiting == nil || !r.waiting() {
+			elapsed += now.Sub(last)
+		}
+		last = now
+		select {
+		case <-p.exited:
+			return fmt.Errorf("process exited prematurely")
+		default:
+		}
+		if elapsed > r.timeout {
+			return fmt.Errorf("timed out after %s", r.timeout)
+		}
+		conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// outputLog keeps the most recent maxOutput bytes of a forwarder's output
+// for error messages, and signals matched once the output matches pattern.
+type outputLog struct {
+	pattern *regexp.Regexp
+	matched chan struct{}
+
+	mu   sync.Mutex
+	buf  []byte
+	done bool
+}
+
+func newOutputLog(pattern *regexp.Regexp) *outputLog {
+	return &outputLog{pattern: pattern, matched: make(chan struct{})}
+}
+
+func (o *outputLog) Write(b []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, b...)
+	if over := len(o.buf) - maxOutput; over > 0 {
+		o.buf = o.buf[over:]
+	}
+	if o.pattern != nil && !o.done && o.pattern.Match(o.buf) {
+		o.done = true
+		close(o.matched)
+	}
+	return len(b), nil
+}
+
+// String returns the captured output, trimmed.
+func (o *outputLog) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.TrimSpace(string(o.buf))
+}
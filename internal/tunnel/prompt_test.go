@@ -0,0 +1,215 @@
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestHostKeyPolicyStrictRejectsUnknownHost(t *testing.T) {
+	keyPath, pub := writeClientKey(t)
+	srv := newTestSSHServer(t, pub)
+
+	cfg := &config.SSH{Host: srv.host(), Port: srv.port(), User: "tester", KeyPath: keyPath,
+		RemotePort: echoServer(t), HostKeyPolicy: config.HostKeyStrict}
+	tun, err := startNative(cfg, testNativeEnv(t), Options{})
+	if err == nil {
+		tun.Stop()
+		t.Fatal("strict policy accepted an unknown host")
+	}
+	if !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestHostKeyPolicyAsk(t *testing.T) {
+	keyPath, pub := writeClientKey(t)
+	srv := newTestSSHServer(t, pub)
+	want := ssh.FingerprintSHA256(srv.hostKey.PublicKey())
+
+	for _, accept := range []bool{false, true} {
+		env := testNativeEnv(t)
+		var asked string
+		cfg := &config.SSH{Host: srv.host(), Port: srv.port(), User: "tester", KeyPath: keyPath,
+			RemotePort: echoServer(t), HostKeyPolicy: config.HostKeyAsk}
+		tun, err := startNative(cfg, env, Options{
+			ConfirmHostKey: func(host, fingerprint string) bool {
+				asked = fingerprint
+				return accept
+			},
+		})
+		if !strings.HasSuffix(asked, want) {
+			t.Errorf("confirm fingerprint = %q, want suffix %q", asked, want)
+		}
+		if accept != (err == nil) {
+			t.Fatalf("accept=%v: startNative err = %v", accept, err)
+		}
+		if err != nil {
+			continue
+		}
+		tun.Stop()
+		data, _ := os.ReadFile(env.knownHosts[0])
+		if len(data) == 0 {
+			t.Error("accepted host key was not recorded")
+		}
+	}
+}
+
+func TestEncryptedKeyPromptsForPassphrase(t *testing.T) {
+	pubKey, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := ssh.NewPublicKey(pubKey)
+	srv := newTestSSHServer(t, pub)
+	cfg := &config.SSH{Host: srv.host(), Port: srv.port(), User: "tester", KeyPath: keyPath, RemotePort: echoServer(t)}
+
+	if tun, err := startNative(cfg, testNativeEnv(t), Options{}); err == nil {
+		tun.Stop()
+		t.Fatal("encrypted key used without a passphrase")
+	}
+
+	prompts := 0
+	states := make(chan State, 4)
+	tun, err := startNative(cfg, testNativeEnv(t), Options{
+		OnState: func(s State) { states <- s },
+		Prompt: func(question string, echo bool) (string, error) {
+			prompts++
+			if echo {
+				t.Error("passphrase prompt should not echo")
+			}
+			return "s3cret", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("startNative: %v", err)
+	}
+	defer tun.Stop()
+
+	// A restart reuses the decrypted key instead of asking again.
+	srv.dropClients()
+	for _, want := range []State{StateReconnecting, StateUp} {
+		select {
+		case got := <-states:
+			if got != want {
+				t.Fatalf("state = %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	if prompts != 1 {
+		t.Errorf("prompted %d times, want 1", prompts)
+	}
+}
+
+func TestAskpassRelay(t *testing.T) {
+	var gotHost, gotFP string
+	s, err := startAskpass(Options{
+		ConfirmHostKey: func(host, fingerprint string) bool {
+			gotHost, gotFP = host, fingerprint
+			return true
+		},
+		Prompt: func(question string, echo bool) (string, error) {
+			return "answer to " + question, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	sock := s.ln.Addr().String()
+
+	hostPrompt := "The authenticity of host 'gw.example.com (192.0.2.7)' can't be established.\n" +
+		"ED25519 key fingerprint is SHA256:abc123.\n" +
+		"Are you sure you want to continue connecting (yes/no/[fingerprint])? "
+	ans, err := askpassRequest(sock, hostPrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans != "yes" || gotHost != "gw.example.com (192.0.2.7)" || gotFP != "ED25519 SHA256:abc123" {
+		t.Errorf("host key relay: answer %q host %q fingerprint %q", ans, gotHost, gotFP)
+	}
+
+	ans, err = askpassRequest(sock, "me@gw's password: ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans != "answer to me@gw's password:" {
+		t.Errorf("password relay: answer %q", ans)
+	}
+}
+
+func TestAutoBackendDoesNotAskTwice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// A stand-in ssh that only records being run.
+	bin := t.TempDir()
+	ran := filepath.Join(bin, "ran")
+	script := "#!/bin/sh\n: >" + ran + "\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	keyPath, pub := writeClientKey(t)
+	srv := newTestSSHServer(t, pub)
+
+	cfg := &config.SSH{Host: srv.host(), Port: srv.port(), User: "tester", KeyPath: keyPath,
+		RemotePort: echoServer(t), HostKeyPolicy: config.HostKeyAsk, Backend: BackendAuto}
+	asked := 0
+	tun, err := Start(cfg, Options{
+		ConfirmHostKey: func(string, string) bool {
+			asked++
+			return false
+		},
+	})
+	if err == nil {
+		tun.Stop()
+		t.Fatal("Start succeeded with the host key rejected")
+	}
+	if asked != 1 {
+		t.Errorf("asked %d times, want 1", asked)
+	}
+	if _, statErr := os.Stat(ran); statErr == nil || strings.Contains(err.Error(), "exec:") {
+		t.Errorf("fell back to exec after asking: %v", err)
+	}
+}
+
+func TestReadinessDeadlinePausedWhilePrompting(t *testing.T) {
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prompting := range []bool{false, true} {
+		cmd := exec.Command("sleep", "1")
+		_, err := startProcess("sleep", cmd, readiness{
+			port:    port,
+			timeout: 100 * time.Millisecond,
+			waiting: func() bool { return prompting },
+		}, NewDiagnostics(0, nil))
+		if err == nil {
+			t.Fatal("startProcess succeeded without a listener")
+		}
+		// While prompting the deadline never passes; the process exiting
+		// ends the wait instead.
+		if got := strings.Contains(err.Error(), "timed out"); got == prompting {
+			t.Errorf("prompting=%v: err = %v", prompting, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// hop is one SSH connection in a tunnel chain.
+type hop struct {
+	Alias   string // name as written in config, used in messages
+	Host    string // network host after HostName resolution
+	Port    int
+	User    string
+	KeyPath string // explicit identity file; empty means agent + default keys
+}
+
+// addr returns the host:port to dial for the hop.
+func (h hop) addr() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+// sshConfigLookup returns the ssh_config value of key for a host alias, or ""
+// when ssh_config does not set it.
+type sshConfigLookup func(alias, key string) string
+
+// userSSHConfig looks keys up in ~/.ssh/config and /etc/ssh/ssh_config.
+// Unparseable files are ignored rather than failing the tunnel.
+func userSSHConfig(alias, key string) string {
+	v, err := ssh_config.GetStrict(alias, key)
+	if err != nil || v == ssh_config.Default(key) {
+		return ""
+	}
+	return v
+}
+
+// resolveHops expands the tunnel config into the ordered list of SSH
+// connections to make: any jump hosts first, the target host last.
+// Jump hosts come from ssh.jump_hosts, or the target's ProxyJump in ssh_config.
+// Hosts may be ssh_config aliases; explicit config values win over ssh_config.
+func resolveHops(cfg *config.SSH, lookup sshConfigLookup) ([]hop, error) {
+	target := resolveHop(cfg.Host, cfg.User, cfg.Port, config.ExpandTilde(cfg.KeyPath), lookup)
+
+	jumps := cfg.JumpHosts
+	if len(jumps) == 0 {
+		if pj := lookup(cfg.Host, "ProxyJump"); pj != "" && !strings.EqualFold(pj, "none") {
+			jumps = strings.Split(pj, ",")
+		}
+	}
+
+	hops := make([]hop, 0, len(jumps)+1)
+	for _, spec := range jumps {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		user, host, port, err := parseJumpSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, resolveHop(host, user, port, "", lookup))
+	}
+	return append(hops, target), nil
+}
+
+// FirstHop returns the host:port the SSH tunnel connects to first — its
+// first jump host, or the SSH host itself — resolved through ssh_config.
+func FirstHop(cfg *config.SSH) (string, error) {
+	hops, err := resolveHops(cfg, userSSHConfig)
+	if err != nil {
+		return "", err
+	}
+	return hops[0].addr(), nil
+}
+
+// resolveHop fills in a single hop from explicit values and ssh_config.
+func resolveHop(alias, user string, port int, keyPath string, lookup sshConfigLookup) hop {
+	h := hop{Alias: alias, Host: alias, Port: port, User: user, KeyPath: keyPath}
+	if v := lookup(alias, "HostName"); v != "" {
+		h.Host = strings.ReplaceAll(v, "%h", alias)
+	}
+	if h.Port == 0 {
+		if v, err := strconv.Atoi(lookup(alias, "Port")); err == nil {
+			h.Port = v
+		} else {
+			h.Port = 22
+		}
+	}
+	if h.User == "" {
+		h.User = lookup(alias, "User")
+	}
+	if h.User == "" {
+		h.User = localUser()
+	}
+	if h.KeyPath == "" {
+		if v := lookup(alias, "IdentityFile"); v != "" {
+			h.KeyPath = config.ExpandTilde(v)
+		}
+	}
+	return h
+}
+
+// parseJumpSpec splits a ProxyJump-style "[user@]host[:port]" entry.
+func parseJumpSpec(spec string) (user, host string, port int, err error) {
+	spec = strings.TrimPrefix(spec, "ssh://")
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		user, spec = spec[:i], spec[i+1:]
+	}
+	host = spec
+	if h, p, splitErr := net.SplitHostPort(spec); splitErr == nil {
+		host = h
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return "", "", 0, fmt.Errorf("invalid port in jump host %q", spec)
+		}
+	}
+	if host == "" {
+		return "", "", 0, fmt.Errorf("invalid jump host %q", spec)
+	}
+	return user, host, port, nil
+}
+
+// localUser returns the login name OpenSSH would default to.
+func localUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
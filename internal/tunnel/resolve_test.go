@@ -0,0 +1,114 @@
+package tunnel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+func lookupFrom(t *testing.T, text string) sshConfigLookup {
+	t.Helper()
+	c, err := ssh_config.Decode(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("parsing ssh_config: %v", err)
+	}
+	return func(alias, key string) string {
+		v, _ := c.Get(alias, key)
+		return v
+	}
+}
+
+const testSSHConfig = `
+Host gw
+  HostName 10.0.0.5
+  User deploy
+  Port 2222
+  IdentityFile /keys/gw
+  ProxyJump bastion
+
+Host bastion
+  HostName bastion.example.com
+  User jump
+
+Host *.internal
+  HostName %h.corp.example.com
+`
+
+func TestResolveHopsAlias(t *testing.T) {
+	hops, err := resolveHops(&config.SSH{Host: "gw"}, lookupFrom(t, testSSHConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hop{
+		{Alias: "bastion", Host: "bastion.example.com", Port: 22, User: "jump"},
+		{Alias: "gw", Host: "10.0.0.5", Port: 2222, User: "deploy", KeyPath: "/keys/gw"},
+	}
+	if !reflect.DeepEqual(hops, want) {
+		t.Errorf("hops =\n %+v\nwant\n %+v", hops, want)
+	}
+}
+
+func TestResolveHopsExplicitValuesWin(t *testing.T) {
+	cfg := &config.SSH{
+		Host:      "gw",
+		User:      "me",
+		Port:      22,
+		KeyPath:   "/keys/mine",
+		JumpHosts: []string{"a@one.example.com:2200", "db.internal"},
+	}
+	hops, err := resolveHops(cfg, lookupFrom(t, testSSHConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 3 {
+		t.Fatalf("got %d hops, want 3: %+v", len(hops), hops)
+	}
+	if h := hops[0]; h.Host != "one.example.com" || h.Port != 2200 || h.User != "a" {
+		t.Errorf("first jump = %+v", h)
+	}
+	if h := hops[1]; h.Host != "db.internal.corp.example.com" || h.Port != 22 {
+		t.Errorf("second jump = %+v", h)
+	}
+	if h := hops[2]; h.Host != "10.0.0.5" || h.Port != 22 || h.User != "me" || h.KeyPath != "/keys/mine" {
+		t.Errorf("target = %+v", h)
+	}
+}
+
+func TestResolveHopsProxyJumpNone(t *testing.T) {
+	lookup := lookupFrom(t, "Host direct\n  HostName 192.0.2.1\n  ProxyJump none\n")
+	hops, err := resolveHops(&config.SSH{Host: "direct", User: "u"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 1 || hops[0].Host != "192.0.2.1" {
+		t.Errorf("hops = %+v, want direct connection", hops)
+	}
+}
+
+func TestParseJumpSpec(t *testing.T) {
+	tests := []struct {
+		spec, user, host string
+		port             int
+	}{
+		{"bastion", "", "bastion", 0},
+		{"me@bastion", "me", "bastion", 0},
+		{"me@bastion:2222", "me", "bastion", 2222},
+		{"ssh://me@[2001:db8::1]:22", "me", "2001:db8::1", 22},
+	}
+	for _, tt := range tests {
+		user, host, port, err := parseJumpSpec(tt.spec)
+		if err != nil {
+			t.Errorf("parseJumpSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if user != tt.user || host != tt.host || port != tt.port {
+			t.Errorf("parseJumpSpec(%q) = %q, %q, %d", tt.spec, user, host, port)
+		}
+	}
+	if _, _, _, err := parseJumpSpec("me@"); err == nil {
+		t.Error("parseJumpSpec(\"me@\") succeeded")
+	}
+}
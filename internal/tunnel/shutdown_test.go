@@ -0,0 +1,103 @@
+//go:build unix
+
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+// assertGone fails if pid still exists, zombie included.
+func assertGone(t *testing.T, pid int) {
+	t.Helper()
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("process %d still exists after shutdown (kill 0: %v)", pid, err)
+	}
+}
+
+// waitPID waits for a shell command to write its pid to path.
+func waitPID(t *testing.T, path string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(path); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("command never started")
+	return 0
+}
+
+func TestStopLeavesNoProcess(t *testing.T) {
+	tun, err := startCommand(t, &config.Tunnel{Command: forwarderCommand(t)}, Options{})
+	if err != nil {
+		t.Fatalf("startCommand: %v", err)
+	}
+	pid := tun.currentTransport().(*processTransport).cmd.Process.Pid
+	tun.Stop()
+	assertGone(t, pid)
+}
+
+func TestContextCancelAbortsStart(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	// Never listens, so the start only ends by cancellation.
+	cmd := "sh -c 'echo $$ > " + pidFile + "; exec sleep 60' {local_port}"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := StartProvider(mustCommandProvider(t, cmd), Options{Context: ctx})
+		errc <- err
+	}()
+	pid := waitPID(t, pidFile)
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("StartProvider succeeded after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancel did not abort the start")
+	}
+	assertGone(t, pid)
+}
+
+func TestContextCancelStopsTunnel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := newCommandProvider(&config.Tunnel{Command: forwarderCommand(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tun, err := StartProvider(p, Options{Context: ctx})
+	if err != nil {
+		t.Fatalf("StartProvider: %v", err)
+	}
+	pid := tun.currentTransport().(*processTransport).cmd.Process.Pid
+	cancel()
+
+	select {
+	case <-tun.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancel did not stop the tunnel")
+	}
+	if err := tun.WaitUp(time.Second); err == nil {
+		t.Error("WaitUp succeeded on a cancelled tunnel")
+	}
+	tun.Stop() // waits for the teardown the cancel started
+	assertGone(t, pid)
+}
@@ -0,0 +1,184 @@
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer is an in-process SSH server that accepts one client key and
+// serves direct-tcpip channels, enough to exercise the native backend.
+type testSSHServer struct {
+	ln      net.Listener
+	hostKey ssh.Signer
+	config  *ssh.ServerConfig
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// newTestSSHServer starts a server that accepts clientKey. It presents an
+// ed25519 host key plus any extraHostKeys.
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey, extraHostKeys ...ssh.Signer) *testSSHServer {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	cfg.AddHostKey(hostKey)
+	for _, k := range extraHostKeys {
+		cfg.AddHostKey(k)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSSHServer{ln: ln, hostKey: hostKey, config: cfg}
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testSSHServer) host() string { return "127.0.0.1" }
+
+func (s *testSSHServer) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+// Close stops accepting and drops every client connection.
+func (s *testSSHServer) Close() {
+	_ = s.ln.Close()
+	s.dropClients()
+}
+
+// dropClients severs every client connection but keeps listening.
+func (s *testSSHServer) dropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, nc)
+		s.mu.Unlock()
+		go s.handle(nc)
+	}
+}
+
+func (s *testSSHServer) handle(nc net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "direct-tcpip" {
+			_ = nch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var dest struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(nch.ExtraData(), &dest); err != nil {
+			_ = nch.Reject(ssh.ConnectionFailed, "bad payload")
+			continue
+		}
+		target, err := net.Dial("tcp", net.JoinHostPort(dest.Host, strconv.Itoa(int(dest.Port))))
+		if err != nil {
+			_ = nch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, creqs, err := nch.Accept()
+		if err != nil {
+			_ = target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(creqs)
+		go func() {
+			defer ch.Close()
+			defer target.Close()
+			done := make(chan struct{}, 2)
+			go func() { _, _ = io.Copy(ch, target); done <- struct{}{} }()
+			go func() { _, _ = io.Copy(target, ch); done <- struct{}{} }()
+			<-done
+		}()
+	}
+}
+
+// echoServer starts a TCP server that echoes every byte back.
+func echoServer(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// writeClientKey generates a client key pair, writes the private half as an
+// OpenSSH key file and returns its path and public key.
+func writeClientKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, sshPub
+}
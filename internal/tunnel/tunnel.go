@@ -1,79 +1,313 @@
-// Package tunnel manages an SSH port-forward tunnel using the system ssh binary.
+// Package tunnel manages a local port forward to the gateway.
+//
+// The transport is supplied by a Provider: SSH, either as a native
+// in-process client built on golang.org/x/crypto/ssh or through the system
+// ssh binary, or an arbitrary forwarding command such as kubectl
+// port-forward. Whatever the provider, the tunnel watches its transport and
+// re-establishes the forward with backoff if it dies.
 package tunnel
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os/exec"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ngmaloney/clawchat-cli/internal/config"
 )
 
-// Tunnel wraps a spawned ssh process providing a local port forward.
+// Tunnel backends accepted in config.SSH.Backend.
+const (
+	BackendAuto   = "auto" // native, falling back to exec
+	BackendNative = "native"
+	BackendExec   = "exec"
+)
+
+// State is the health of a running tunnel.
+type State string
+
+const (
+	StateUp           State = "up"
+	StateReconnecting State = "reconnecting"
+	StateDown         State = "down" // gave up restarting
+)
+
+// Restart backoff bounds.
+const (
+	restartBaseDelay = time.Second
+	restartMaxDelay  = 30 * time.Second
+	maxRestarts      = 10
+)
+
+// Provider brings up the transport behind a Tunnel. The Tunnel owns the
+// lifecycle: it picks the local port, calls Connect, watches the returned
+// transport and calls Connect again with backoff when it dies.
+type Provider interface {
+	// Name identifies the provider in the UI, e.g. "SSH" or "kubectl".
+	Name() string
+
+	// InProcess reports whether transports carry connections themselves
+	// (see Dialer). If so the Tunnel listens on the local port and hands
+	// each connection to the current transport; otherwise Connect must
+	// start something listening on localPort.
+	InProcess() bool
+
+	// Connect establishes a transport for localPort and blocks until it is
+	// ready to carry connections. Output and events worth showing the user
+	// are recorded in diag. ctx is cancelled when the tunnel stops; any
+	// process started for the transport must die with it.
+	Connect(ctx context.Context, localPort int, diag *Diagnostics) (Transport, error)
+
+	// Close releases anything the provider keeps across transports. It is
+	// called once, after the last transport has been closed.
+	Close()
+}
+
+// Transport is one live connection established by a Provider.
+type Transport interface {
+	// Done is closed when the transport dies.
+	Done() <-chan struct{}
+
+	// Close tears the transport down and waits for it to exit.
+	Close()
+}
+
+// Dialer is implemented by the transports of in-process providers.
+type Dialer interface {
+	// DialContext opens a connection to the gateway over the transport.
+	DialContext(ctx context.Context) (net.Conn, error)
+}
+
+// Options configures a Tunnel.
+type Options struct {
+	// OnState is called from a background goroutine whenever the tunnel's
+	// state changes after startup.
+	OnState func(State)
+
+	// ConfirmHostKey is asked whether to trust a host that is not yet in
+	// known_hosts when the host key policy is "ask". It blocks until the user
+	// answers. A nil func rejects unknown hosts under that policy.
+	ConfirmHostKey func(host, fingerprint string) bool
+
+	// Prompt asks the user for a key passphrase, a password or a
+	// keyboard-interactive answer; echo reports whether the answer may be
+	// shown while typed. A nil func keeps SSH non-interactive (BatchMode).
+	Prompt func(question string, echo bool) (string, error)
+
+	// Context, if set, bounds the tunnel's life: cancelling it aborts a
+	// start in progress and stops a running tunnel.
+	Context context.Context
+
+	// Diagnostics receives the tunnel's output and events. Pass one in to
+	// keep it across tunnels or to inspect a failed start; nil creates one
+	// per tunnel.
+	Diagnostics *Diagnostics
+}
+
+// Tunnel is a running local port forward to the remote gateway.
 type Tunnel struct {
 	LocalPort int
-	proc      *exec.Cmd
+
+	provider  Provider
+	opts      Options
+	diag      *Diagnostics
+	ctx       context.Context // cancelled by Stop
+	cancel    context.CancelFunc
+	baseDelay time.Duration // restart backoff bounds, shortened in tests
+	maxDelay  time.Duration
+
+	mu        sync.Mutex
+	state     State
+	changed   chan struct{} // closed and replaced on every state change
+	transport Transport
+	listener  net.Listener // held for in-process providers
+
+	stopped  chan struct{}
+	stopOnce sync.Once
 }
 
-// Start establishes the SSH tunnel and returns the local port to connect to.
-// It blocks until the tunnel is ready (local port accepts connections) or fails.
-func Start(cfg *config.SSH) (*Tunnel, error) {
-	localPort, err := freePort()
-	if err != nil {
-		return nil, fmt.Errorf("finding free port: %w", err)
+// Start establishes the SSH tunnel using the configured backend, or through
+// the ControlMaster at cfg.ControlPath if one is set and running.
+// It blocks until the tunnel is ready to carry connections or fails.
+func Start(cfg *config.SSH, opts Options) (*Tunnel, error) {
+	if cfg.ControlPath != "" {
+		return StartProvider(newControlProvider(cfg, opts), opts)
+	}
+	switch backend(cfg) {
+	case BackendNative:
+		return StartProvider(newNativeProvider(cfg, opts), opts)
+	case BackendExec:
+		return StartProvider(newExecProvider(cfg, opts), opts)
 	}
 
-	keyPath := config.ExpandTilde(cfg.KeyPath)
-	remotePort := cfg.RemotePort
-	if remotePort == 0 {
-		remotePort = 18789
+	// Once the user has been asked something — a passphrase, a new host
+	// key — native got far enough that ssh would ask the same again, so its
+	// error stands rather than prompting twice.
+	var asked atomic.Bool
+	nativeOpts := opts
+	if opts.Prompt != nil {
+		nativeOpts.Prompt = func(question string, echo bool) (string, error) {
+			asked.Store(true)
+			return opts.Prompt(question, echo)
+		}
+	}
+	if opts.ConfirmHostKey != nil {
+		nativeOpts.ConfirmHostKey = func(host, fingerprint string) bool {
+			asked.Store(true)
+			return opts.ConfirmHostKey(host, fingerprint)
+		}
 	}
-	sshPort := cfg.Port
-	if sshPort == 0 {
-		sshPort = 22
+	t, nativeErr := StartProvider(newNativeProvider(cfg, nativeOpts), nativeOpts)
+	if nativeErr == nil || asked.Load() {
+		return t, nativeErr
 	}
-
-	args := []string{
-		"-N",
-		"-o", "StrictHostKeyChecking=accept-new",
-		"-o", "ExitOnForwardFailure=yes",
-		"-o", "ServerAliveInterval=30",
-		"-o", "BatchMode=yes",
-		"-L", fmt.Sprintf("%d:127.0.0.1:%d", localPort, remotePort),
-		"-p", fmt.Sprintf("%d", sshPort),
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, nativeErr
 	}
-	if keyPath != "" {
-		args = append(args, "-i", keyPath)
+	t, execErr := StartProvider(newExecProvider(cfg, opts), opts)
+	if execErr != nil {
+		return nil, errors.Join(fmt.Errorf("native: %w", nativeErr), fmt.Errorf("exec: %w", execErr))
 	}
-	args = append(args, fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
-
-	proc := exec.Command("ssh", args...)
+	return t, nil
+}
 
-	var stderr strings.Builder
-	proc.Stderr = &stderr
+// StartProvider establishes a tunnel over p. It blocks until the tunnel is
+// ready to carry connections or fails, in which case p is closed.
+func StartProvider(p Provider, opts Options) (*Tunnel, error) {
+	return newTunnel(p, opts).start()
+}
 
-	if err := proc.Start(); err != nil {
-		return nil, fmt.Errorf("starting ssh: %w", err)
+func newTunnel(p Provider, opts Options) *Tunnel {
+	diag := opts.Diagnostics
+	if diag == nil {
+		diag = NewDiagnostics(0, nil)
 	}
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	return &Tunnel{
+		provider:  p,
+		opts:      opts,
+		diag:      diag,
+		ctx:       ctx,
+		cancel:    cancel,
+		baseDelay: restartBaseDelay,
+		maxDelay:  restartMaxDelay,
+		changed:   make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
 
-	t := &Tunnel{LocalPort: localPort, proc: proc}
+// start claims the local port, brings up the transport and starts the monitor.
+// In-process providers keep the listener for the tunnel's whole lifetime, so
+// there is no window in which another process can take the port.
+func (t *Tunnel) start() (*Tunnel, error) {
+	if t.provider.InProcess() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.cancel()
+			t.provider.Close()
+			return nil, fmt.Errorf("listening on local port: %w", err)
+		}
+		t.listener = ln
+		t.LocalPort = ln.Addr().(*net.TCPAddr).Port
+	} else {
+		port, err := freePort()
+		if err != nil {
+			t.cancel()
+			t.provider.Close()
+			return nil, fmt.Errorf("finding free port: %w", err)
+		}
+		t.LocalPort = port
+	}
 
-	// Poll until the local port is accepting connections.
-	if err := t.waitReady(15 * time.Second); err != nil {
-		_ = proc.Process.Kill()
-		return nil, fmt.Errorf("tunnel did not become ready: %w (ssh stderr: %s)", err, stderr.String())
+	tr, err := t.provider.Connect(t.ctx, t.LocalPort, t.diag)
+	if err == nil && t.ctx.Err() != nil {
+		tr.Close()
+		err = t.ctx.Err()
 	}
+	if err != nil {
+		t.diag.Logf("%s: %v", t.Name(), err)
+		t.cancel()
+		if t.listener != nil {
+			_ = t.listener.Close()
+		}
+		t.provider.Close()
+		return nil, err
+	}
+	t.transport = tr
+	t.state = StateUp
+	t.diag.Logf("%s: forwarding 127.0.0.1:%d", t.Name(), t.LocalPort)
 
+	if t.listener != nil {
+		go t.acceptLoop()
+	}
+	go t.monitor()
+	context.AfterFunc(t.ctx, t.Stop)
 	return t, nil
 }
 
-// Stop kills the SSH tunnel process.
+// Name returns the name of the tunnel's provider.
+func (t *Tunnel) Name() string {
+	return t.provider.Name()
+}
+
+// Diagnostics returns the tunnel's recent output and events.
+func (t *Tunnel) Diagnostics() *Diagnostics {
+	return t.diag
+}
+
+// Stop tears down the tunnel and waits for its transport to exit.
 func (t *Tunnel) Stop() {
-	if t.proc != nil && t.proc.Process != nil {
-		_ = t.proc.Process.Kill()
-		_ = t.proc.Wait()
+	t.stopOnce.Do(func() {
+		close(t.stopped)
+		t.cancel()
+		if t.listener != nil {
+			_ = t.listener.Close()
+		}
+		t.closeTransport()
+		t.provider.Close()
+	})
+}
+
+// State returns the tunnel's current health.
+func (t *Tunnel) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// WaitUp blocks until the tunnel is up, returning an error if it is down,
+// stopped, or does not recover within timeout.
+func (t *Tunnel) WaitUp(timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		if t.isStopped() {
+			return fmt.Errorf("%s tunnel stopped", t.Name())
+		}
+		t.mu.Lock()
+		state, changed := t.state, t.changed
+		t.mu.Unlock()
+		switch state {
+		case StateUp:
+			return nil
+		case StateDown:
+			return fmt.Errorf("%s tunnel is down", t.Name())
+		}
+		select {
+		case <-changed:
+		case <-t.stopped:
+			return fmt.Errorf("%s tunnel stopped", t.Name())
+		case <-deadline:
+			return fmt.Errorf("%s tunnel did not recover within %s", t.Name(), timeout)
+		}
 	}
 }
 
@@ -82,22 +316,133 @@ func (t *Tunnel) GatewayURL() string {
 	return fmt.Sprintf("ws://127.0.0.1:%d", t.LocalPort)
 }
 
-// waitReady polls the local port until it accepts a TCP connection.
-func (t *Tunnel) waitReady(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		// Check if the process died early
-		if t.proc.ProcessState != nil {
-			return fmt.Errorf("ssh process exited prematurely")
+// DialContext opens a connection to the remote gateway through the tunnel.
+// In-process transports are dialed directly, without a round trip through
+// the local listener; addr is ignored.
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d, ok := t.currentTransport().(Dialer); ok {
+		return d.DialContext(ctx)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", t.LocalPort))
+}
+
+// acceptLoop forwards each local connection over the current transport.
+func (t *Tunnel) acceptLoop() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
 		}
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", t.LocalPort), 200*time.Millisecond)
-		if err == nil {
-			conn.Close()
-			return nil
+		go func() {
+			defer local.Close()
+			d, ok := t.currentTransport().(Dialer)
+			if !ok {
+				return
+			}
+			remote, err := d.DialContext(context.Background())
+			if err != nil {
+				return
+			}
+			defer remote.Close()
+			pipe(local, remote)
+		}()
+	}
+}
+
+// closeTransport shuts down the current transport, if any.
+func (t *Tunnel) closeTransport() {
+	t.mu.Lock()
+	tr := t.transport
+	t.transport = nil
+	t.mu.Unlock()
+	if tr != nil {
+		tr.Close()
+	}
+}
+
+// monitor watches the transport and restarts it with backoff when it dies.
+func (t *Tunnel) monitor() {
+	for {
+		if tr := t.currentTransport(); tr != nil {
+			select {
+			case <-tr.Done():
+			case <-t.stopped:
+			}
 		}
-		time.Sleep(200 * time.Millisecond)
+		if t.isStopped() {
+			return
+		}
+		t.diag.Logf("%s: connection lost, restarting", t.Name())
+		t.setState(StateReconnecting)
+		if !t.restart() {
+			if !t.isStopped() {
+				t.diag.Logf("%s: giving up after %d attempts", t.Name(), maxRestarts)
+				t.setState(StateDown)
+			}
+			return
+		}
+		t.diag.Logf("%s: reconnected", t.Name())
+		t.setState(StateUp)
 	}
-	return fmt.Errorf("timed out after %s", timeout)
+}
+
+// restart re-establishes the transport, backing off between attempts.
+func (t *Tunnel) restart() bool {
+	delay := t.baseDelay
+	for attempt := 0; attempt < maxRestarts; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-t.stopped:
+			return false
+		}
+		delay = min(delay*2, t.maxDelay)
+
+		t.closeTransport()
+		tr, err := t.provider.Connect(t.ctx, t.LocalPort, t.diag)
+		if err != nil {
+			t.diag.Logf("%s: restart attempt %d failed: %v", t.Name(), attempt+1, err)
+			continue
+		}
+		t.mu.Lock()
+		t.transport = tr
+		t.mu.Unlock()
+		if t.isStopped() {
+			t.closeTransport()
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (t *Tunnel) setState(s State) {
+	t.mu.Lock()
+	changed := t.state != s
+	t.state = s
+	if changed {
+		close(t.changed)
+		t.changed = make(chan struct{})
+	}
+	t.mu.Unlock()
+	if changed && t.opts.OnState != nil {
+		t.opts.OnState(s)
+	}
+}
+
+func (t *Tunnel) isStopped() bool {
+	select {
+	case <-t.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *Tunnel) currentTransport() Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transport
 }
 
 // freePort finds an available local TCP port.
@@ -110,3 +455,25 @@ func freePort() (int, error) {
 	_ = ln.Close()
 	return port, nil
 }
+
+// pipe copies in both directions until either side closes.
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(a, b); done <- struct{}{} }()
+	go func() { _, _ = io.Copy(b, a); done <- struct{}{} }()
+	<-done
+}
+
+func backend(cfg *config.SSH) string {
+	if cfg.Backend == "" {
+		return BackendAuto
+	}
+	return cfg.Backend
+}
+
+func hostKeyPolicy(cfg *config.SSH) string {
+	if cfg.HostKeyPolicy == "" {
+		return config.HostKeyAcceptNew
+	}
+	return cfg.HostKeyPolicy
+}
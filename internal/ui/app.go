@@ -2,16 +2,26 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/ngmaloney/clawchat-cli/internal/config"
 	"github.com/ngmaloney/clawchat-cli/internal/gateway"
 	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
@@ -33,55 +43,153 @@ const (
 type connectDoneMsg struct {
 	sessionKey string
 	session    gateway.Session
-	history    []gateway.Message
+	history    gateway.HistoryPage
 	client     *gateway.Client
 	tun        *tunnel.Tunnel
 }
 
-type connectErrMsg struct{ err error }
+// connectErrMsg reports a failed connect. If only the configured session
+// was missing, the connection is handed over too, so another session can be
+// picked without reconnecting.
+type connectErrMsg struct {
+	err      error
+	client   *gateway.Client
+	tun      *tunnel.Tunnel
+	sessions []gateway.Session
+}
+
+// configEditedMsg reports that the editor opened from the error screen exited.
+type configEditedMsg struct{ err error }
 
 type chatEventMsg gateway.ChatEvent
+type tunnelStateMsg tunnel.State
+
+// gatewayStatusMsg is a status change of client. Changes from a client that
+// has since been replaced are ignored.
+type gatewayStatusMsg struct {
+	client *gateway.Client
+	status gateway.Status
+}
+
+// promptMsg asks the user a question on behalf of the SSH tunnel while it
+// connects. The tunnel goroutine blocks until an answer arrives on reply.
+type promptMsg struct {
+	hostKey bool   // true: trust-this-host confirmation; false: secret entry
+	title   string // host for hostKey prompts
+	text    string // fingerprint or question
+	echo    bool
+	reply   chan promptReply
+}
+
+type promptReply struct {
+	answer string
+	ok     bool
+}
+type approvalRequestMsg gateway.ApprovalRequest
+type approvalResolvedMsg struct{ id string }
+type inboxMsg struct{}
+type approvalDoneMsg struct {
+	id  string
+	err error
+}
 type sendDoneMsg struct{ runID string }
-type historyReloadMsg []gateway.Message
+type historyReloadMsg gateway.HistoryPage
 type sessionsLoadedMsg []gateway.Session
 
 // ── Rendered message ──────────────────────────────────────────────────────────
 
+// renderMsg is one transcript entry. role and content are the source of
+// truth; the rendering is cached for the width it was made at, plus the one
+// before, so a resize re-renders each message once and switching back (e.g.
+// zooming a tmux pane) costs nothing.
 type renderMsg struct {
+	id        string // gateway message ID, once known
 	role      string
 	content   string
-	rendered  string
 	timestamp time.Time
+	style     lipgloss.Style // for notes, which have no role
+	queued    bool           // user message waiting for the gateway to come back
+
+	cur, prev rendering
+}
+
+// rendering is a message laid out at one width.
+type rendering struct {
+	width  int
+	height int // lines
+	text   string
+}
+
+func chatMsg(role, content string, ts time.Time) renderMsg {
+	return renderMsg{role: role, content: content, timestamp: ts}
+}
+
+// noteMsg is a client-side line in the transcript: an error, hint or help.
+func noteMsg(style lipgloss.Style, text string) renderMsg {
+	return renderMsg{content: text, style: style}
 }
 
 // ── App ───────────────────────────────────────────────────────────────────────
 
 type App struct {
-	cfg   *config.Config
-	state appState
-	err   error
+	cfg      *config.Config
+	state    appState
+	err      error
+	failed   connectErrMsg // the connect behind err
+	checks   []check       // connection diagnostics run from the error screen
+	checking bool
 
-	client *gateway.Client
-	tun    *tunnel.Tunnel
+	client     *gateway.Client
+	connStatus gateway.Status // last status reported by the client
+	tun        *tunnel.Tunnel
+	tunState   tunnel.State
+	diag       *tunnel.Diagnostics // tunnel output, kept across connect attempts
+	showDiag   bool
+	life       *lifecycle
 
 	sessionKey string
 	session    gateway.Session
 
-	messages    []renderMsg
-	streamRunID string
-	streamBuf   string
-	localRunID  string // run ID of the most recent locally-initiated send
-	isWaiting   bool   // true between send and first assistant token — shows "thinking" indicator
-
-	events chan gateway.ChatEvent
+	messages     []renderMsg
+	streams      []*liveStream // replies still streaming, in the order they started
+	localRunID   string        // run ID of the most recent locally-initiated send
+	promptRun    string        // run ID of the last prompt seen as a chat event
+	finishedRuns []string      // recently finished run IDs, oldest first
+	olderCursor  string        // fetches the history page before the oldest message; "" at the start
+	loadingOlder bool
+	queue        []queuedSend // messages typed while the gateway was reconnecting
+	isWaiting    bool         // true between send and first assistant token — shows "thinking" indicator
+
+	dark   bool      // terminal background, picks the markdown palette
+	md     *markdown // assistant message renderer for the current width
+	mdPrev *markdown // and for the previous one
+
+	events chan tea.Msg // gateway events, converted to tea messages
+
+	// exec approval requests awaiting a decision, oldest first
+	inbox         *eventInbox // events that must not be dropped, not yet seen by Update
+	approvals     []gateway.ApprovalRequest
+	approvalAt    time.Time // when approvals[0] was first shown
+	approvalBusy  string    // ID of the request being resolved
+	confirmAlways bool      // "a" pressed once; a second "a" approves always
+
+	// pending tunnel prompt shown on the connecting screen
+	prompt      *promptMsg
+	promptInput textinput.Model
 
 	// session picker
-	sessions  []gateway.Session
-	pickerIdx int
-
-	viewport viewport.Model
-	input    textarea.Model
-	spin     spinner.Model
+	sessions     []gateway.Session
+	pickerIdx    int
+	pickerAction pickerAction      // operation being filled in or confirmed
+	pickerForm   []textinput.Model // its fields
+	pickerField  int
+	pickerErr    string // why the last operation failed
+
+	chat       transcript
+	frames     frameLimiter // paces redraws while a reply streams
+	streamView string       // rendered thinking indicator or live reply, "" if neither
+	input      textarea.Model
+	spin       spinner.Model
 
 	width  int
 	height int
@@ -89,13 +197,15 @@ type App struct {
 	msgSeq int
 }
 
-func New(cfg *config.Config) *App {
+// New returns the root model. Tunnel diagnostics are also written to log,
+// if non-nil.
+func New(cfg *config.Config, log io.Writer) *App {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	sp.Style = styleBadgeConnecting
 
 	ti := textarea.New()
-	ti.Placeholder = "Type a message…"
+	ti.Placeholder = placeholderOnline
 	ti.CharLimit = 4096
 	ti.ShowLineNumbers = false
 	ti.SetHeight(3)
@@ -106,57 +216,149 @@ func New(cfg *config.Config) *App {
 	ti.BlurredStyle.Base = noBorder
 	ti.FocusedStyle.CursorLine = noBorder
 	ti.BlurredStyle.CursorLine = noBorder
+	if cfg.ReadOnly {
+		ti.Placeholder = placeholderReadOnly
+	}
 	ti.Focus()
 
+	pi := textinput.New()
+	pi.Prompt = "› "
+	pi.CharLimit = 1024
+
 	return &App{
-		cfg:    cfg,
-		state:  stateConnecting,
-		spin:   sp,
-		input:  ti,
-		events: make(chan gateway.ChatEvent, 64),
+		cfg:         cfg,
+		state:       stateConnecting,
+		spin:        sp,
+		input:       ti,
+		promptInput: pi,
+		diag:        tunnel.NewDiagnostics(0, log),
+		life:        newLifecycle(),
+		events:      make(chan tea.Msg, 64),
+		inbox:       &eventInbox{},
+		dark:        lipgloss.HasDarkBackground(),
+		frames:      newFrameLimiter(cfg.FrameRate()),
 	}
 }
 
 // ── Init ──────────────────────────────────────────────────────────────────────
 
 func (a *App) Init() tea.Cmd {
-	return tea.Batch(a.spin.Tick, a.connectCmd())
+	return tea.Batch(a.spin.Tick, a.connectCmd(), waitForEvent(a.events))
 }
 
 func (a *App) connectCmd() tea.Cmd {
 	events := a.events
+	life := a.life
+	inbox := a.inbox
+	approvals := a.approvalsEnabled()
 	return func() tea.Msg {
+		if !life.begin() {
+			return nil
+		}
+		defer life.end()
+
 		var tun *tunnel.Tunnel
 		gatewayURL := a.cfg.GatewayURL
+		var dial func(ctx context.Context, network, addr string) (net.Conn, error)
 
-		if a.cfg.SSHEnabled() {
-			t, err := tunnel.Start(a.cfg.SSH)
+		opts := tunnel.Options{
+			OnState: func(s tunnel.State) {
+				select {
+				case events <- tunnelStateMsg(s):
+				default:
+				}
+			},
+			ConfirmHostKey: func(host, fingerprint string) bool {
+				r := ask(life.ctx, events, promptMsg{hostKey: true, title: host, text: fingerprint})
+				return r.ok && r.answer == "yes"
+			},
+			Prompt: func(question string, echo bool) (string, error) {
+				r := ask(life.ctx, events, promptMsg{text: question, echo: echo})
+				if !r.ok {
+					return "", fmt.Errorf("prompt cancelled")
+				}
+				return r.answer, nil
+			},
+			Context:     life.ctx,
+			Diagnostics: a.diag,
+		}
+		switch {
+		case a.cfg.SSHEnabled():
+			t, err := tunnel.Start(a.cfg.SSH, opts)
+			if err != nil {
+				return connectErrMsg{err: fmt.Errorf("SSH tunnel: %w", err)}
+			}
+			tun = t
+		case a.cfg.TunnelEnabled():
+			t, err := tunnel.StartCommand(a.cfg.Tunnel, opts)
 			if err != nil {
-				return connectErrMsg{fmt.Errorf("SSH tunnel: %w", err)}
+				return connectErrMsg{err: fmt.Errorf("tunnel: %w", err)}
 			}
 			tun = t
-			gatewayURL = t.GatewayURL()
+		}
+		if tun != nil {
+			if !life.adoptTunnel(tun) {
+				return nil
+			}
+			gatewayURL = tun.GatewayURL()
+			dial = tun.DialContext
 		}
 
-		client := gateway.New(gateway.Options{
-			URL:   gatewayURL,
-			Token: a.cfg.Token,
+		var client *gateway.Client
+		client = gateway.New(gateway.Options{
+			URL:            gatewayURL,
+			Token:          a.cfg.Token,
+			AuthMode:       gateway.AuthMode(a.cfg.AuthMode()),
+			Password:       a.cfg.Auth.Password,
+			DeviceToken:    a.cfg.Auth.DeviceToken,
+			Scopes:         a.cfg.RequestedScopes(),
+			NetDialContext: dial,
+			OnStatus: func(s gateway.Status) {
+				// Unlike events, a status change must not be dropped: the
+				// header and input would be stuck in the old state.
+				select {
+				case events <- gatewayStatusMsg{client: client, status: s}:
+				case <-life.ctx.Done():
+				}
+			},
+			BeforeReconnect: func() error {
+				if tun == nil {
+					return nil
+				}
+				// Let the tunnel re-establish its forward before re-dialing.
+				return tun.WaitUp(2 * time.Minute)
+			},
 			OnEvent: func(event string, payload map[string]any) {
-				if event == "chat" {
-					ev := gateway.ParseChatEvent(payload)
-					select {
-					case events <- ev:
-					default:
+				var msg tea.Msg
+				switch event {
+				case "chat":
+					msg = chatEventMsg(gateway.ParseChatEvent(payload))
+				case "exec.approval.requested":
+					if !approvals {
+						return
 					}
+					msg = approvalRequestMsg(gateway.ParseApprovalRequest(payload))
+				case "exec.approval.resolved":
+					if !approvals {
+						return
+					}
+					msg = approvalResolvedMsg{id: gateway.ParseApprovalResolved(payload)}
+				default:
+					return
 				}
+				deliver(events, inbox, msg)
 			},
 		})
 
+		if !life.adoptClient(client) {
+			return nil
+		}
 		if err := client.Connect(); err != nil {
+			client.Close()
 			if tun != nil {
 				tun.Stop()
 			}
-			return connectErrMsg{fmt.Errorf("gateway: %w", err)}
+			return connectErrMsg{err: fmt.Errorf("gateway: %w", err)}
 		}
 
 		sessions, err := client.ListSessions()
@@ -165,7 +367,7 @@ func (a *App) connectCmd() tea.Cmd {
 			if tun != nil {
 				tun.Stop()
 			}
-			return connectErrMsg{fmt.Errorf("listing sessions: %w", err)}
+			return connectErrMsg{err: fmt.Errorf("listing sessions: %w", err)}
 		}
 
 		var session gateway.Session
@@ -177,11 +379,12 @@ func (a *App) connectCmd() tea.Cmd {
 				}
 			}
 			if session.Key == "" {
-				client.Close()
-				if tun != nil {
-					tun.Stop()
+				return connectErrMsg{
+					err:      fmt.Errorf("session %q not found", a.cfg.SessionKey),
+					client:   client,
+					tun:      tun,
+					sessions: sessions,
 				}
-				return connectErrMsg{fmt.Errorf("session %q not found", a.cfg.SessionKey)}
 			}
 		} else if len(sessions) > 0 {
 			session = sessions[0]
@@ -190,10 +393,10 @@ func (a *App) connectCmd() tea.Cmd {
 			if tun != nil {
 				tun.Stop()
 			}
-			return connectErrMsg{fmt.Errorf("no sessions available")}
+			return connectErrMsg{err: fmt.Errorf("no sessions available")}
 		}
 
-		history, _ := client.GetHistory(session.Key, 50)
+		history, _ := client.GetHistory(session.Key, a.cfg.HistoryPageSize(), "")
 
 		return connectDoneMsg{
 			sessionKey: session.Key,
@@ -205,8 +408,41 @@ func (a *App) connectCmd() tea.Cmd {
 	}
 }
 
-func waitForEvent(ch <-chan gateway.ChatEvent) tea.Cmd {
-	return func() tea.Msg { return chatEventMsg(<-ch) }
+// deliver hands a gateway event to Update without blocking the read loop.
+// A delta may be dropped when events is full: each carries the reply so far,
+// so the next makes up for it. Anything else dropped would leave a reply
+// streaming or an agent waiting, so it goes to the inbox, which never fills;
+// if the wake-up is dropped, the status tick drains it.
+func deliver(events chan<- tea.Msg, inbox *eventInbox, msg tea.Msg) {
+	if ev, ok := msg.(chatEventMsg); !ok || ev.State != "delta" {
+		inbox.push(msg)
+		msg = inboxMsg{}
+	}
+	select {
+	case events <- msg:
+	default:
+	}
+}
+
+// ask posts a prompt to the UI and blocks until the user answers it or ctx
+// is cancelled, which counts as declining.
+func ask(ctx context.Context, events chan<- tea.Msg, p promptMsg) promptReply {
+	p.reply = make(chan promptReply, 1)
+	select {
+	case events <- p:
+	case <-ctx.Done():
+		return promptReply{}
+	}
+	select {
+	case r := <-p.reply:
+		return r
+	case <-ctx.Done():
+		return promptReply{}
+	}
+}
+
+func waitForEvent(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg { return <-ch }
 }
 
 // ── Update ────────────────────────────────────────────────────────────────────
@@ -225,9 +461,23 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch a.state {
 		case stateConnecting:
 			if msg.String() == "ctrl+c" {
+				a.cleanup()
 				return a, tea.Quit
 			}
+			if a.prompt != nil {
+				return a, a.handlePromptKey(msg)
+			}
 		case stateChat:
+			if a.prompt != nil {
+				// The tunnel is re-authenticating after a restart.
+				return a, a.handlePromptKey(msg)
+			}
+			if a.showDiag {
+				return a, a.handleDiagKey(msg)
+			}
+			if a.approvalsEnabled() && len(a.approvals) > 0 {
+				return a, a.handleApprovalKey(msg)
+			}
 			if cmd := a.handleKey(msg); cmd != nil {
 				return a, cmd
 			}
@@ -236,7 +486,7 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return a, cmd
 			}
 		case stateError:
-			return a, tea.Quit
+			return a, a.handleErrorKey(msg)
 		}
 
 	case spinner.TickMsg:
@@ -247,29 +497,41 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case connectDoneMsg:
-		a.client = msg.client
-		a.tun = msg.tun
+		a.adopt(msg.client, msg.tun)
 		a.sessionKey = msg.sessionKey
 		a.session = msg.session
-		a.messages = make([]renderMsg, 0, len(msg.history))
-		for _, m := range msg.history {
-			a.messages = append(a.messages, a.renderMessage(m.Role, m.Content, m.Timestamp))
-		}
+		a.setHistory(msg.history)
 		a.state = stateChat
+		a.chat.gotoBottom()
 		a.rebuildLayout()
-		a.flushViewport()
-		cmds = append(cmds, waitForEvent(a.events))
+		a.flushTranscript()
+		cmds = append(cmds, statusTick())
 
 	case connectErrMsg:
 		a.err = msg.err
+		a.failed = msg
 		a.state = stateError
 
+	case configEditedMsg:
+		if cmd := a.configEdited(msg.err); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+
+	case checksDoneMsg:
+		a.checks = msg
+		a.checking = false
+
 	case sessionsLoadedMsg:
+		// Pre-select the current session, or keep the selection when the
+		// list is refreshed after a change.
+		want := a.sessionKey
+		if a.state == stateSessionPicker && a.pickerIdx < len(a.sessions) {
+			want = a.sessions[a.pickerIdx].Key
+		}
 		a.sessions = []gateway.Session(msg)
 		a.pickerIdx = 0
-		// Pre-select the current session
 		for i, s := range a.sessions {
-			if s.Key == a.sessionKey {
+			if s.Key == want {
 				a.pickerIdx = i
 				break
 			}
@@ -282,15 +544,90 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		cmds = append(cmds, waitForEvent(a.events))
 
+	case promptMsg:
+		a.prompt = &msg
+		a.promptInput.Reset()
+		a.promptInput.EchoMode = textinput.EchoPassword
+		if msg.echo {
+			a.promptInput.EchoMode = textinput.EchoNormal
+		}
+		cmds = append(cmds, a.promptInput.Focus(), waitForEvent(a.events))
+
+	case gatewayStatusMsg:
+		if msg.client != a.client {
+			cmds = append(cmds, waitForEvent(a.events))
+			break
+		}
+		prev := a.connStatus
+		a.connStatus = msg.status
+		a.syncInput()
+		if a.connStatus == gateway.StatusConnected && prev != gateway.StatusConnected {
+			cmds = append(cmds, a.flushQueue())
+		}
+		cmds = append(cmds, waitForEvent(a.events))
+
+	case tunnelStateMsg:
+		prev := a.tunState
+		a.tunState = tunnel.State(msg)
+		if a.state == stateChat && a.tun != nil && a.tunState != tunnel.StateUp && a.tunState != prev {
+			a.noteTunnelProblem()
+		}
+		cmds = append(cmds, waitForEvent(a.events))
+
+	case approvalRequestMsg, approvalResolvedMsg:
+		a.updateApprovals(func() { a.applyApproval(msg) })
+		cmds = append(cmds, waitForEvent(a.events))
+
+	case inboxMsg:
+		cmds = append(cmds, a.drainInbox(), waitForEvent(a.events))
+
+	case approvalDoneMsg:
+		if msg.id == a.approvalBusy {
+			a.approvalBusy = ""
+		}
+		if msg.err != nil {
+			// Leave the request at the head of the queue to be answered again.
+			a.appendMsg(noteMsg(styleError, "⚠ approval failed: "+msg.err.Error()))
+			break
+		}
+		a.updateApprovals(func() { a.dropApproval(msg.id) })
+
 	case sendDoneMsg:
-		a.localRunID = msg.runID
+		// The run may already have finished if its final beat the reply.
+		if !a.runFinished(msg.runID) {
+			a.localRunID = msg.runID
+		}
+
+	case historySyncMsg:
+		if msg.sessionKey == a.sessionKey {
+			a.reconcile(msg.history)
+		}
+
+	case sessionOpMsg:
+		if cmd := a.sessionOpDone(msg); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+
+	case olderHistoryMsg:
+		a.addOlder(msg)
 
 	case historyReloadMsg:
-		a.messages = make([]renderMsg, 0, len(msg))
-		for _, m := range msg {
-			a.messages = append(a.messages, a.renderMessage(m.Role, m.Content, m.Timestamp))
+		a.setHistory(gateway.HistoryPage(msg))
+		a.chat.gotoBottom()
+		a.flushTranscript()
+
+	case statusTickMsg:
+		cmds = append(cmds, a.drainInbox(), statusTick())
+
+	case sessionInfoMsg:
+		if msg.Key == a.sessionKey {
+			a.session = gateway.Session(msg)
+		}
+
+	case frameMsg:
+		if a.frames.tick() {
+			a.flushTranscript()
 		}
-		a.flushViewport()
 
 	case nil:
 		// no-op
@@ -298,20 +635,18 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	if a.state == stateChat && a.ready {
-		var vpCmd, tiCmd tea.Cmd
+		var tiCmd tea.Cmd
 		if keyMsg, ok := msg.(tea.KeyMsg); ok {
-			// Route scroll keys to viewport only — prevents typed chars from scrolling
-			switch keyMsg.String() {
-			case "up", "down", "pgup", "pgdown", "ctrl+u", "ctrl+d", "home", "end":
-				a.viewport, vpCmd = a.viewport.Update(msg)
-			default:
+			// Scroll keys go to the transcript only — typed chars never scroll
+			if a.chat.handleKey(a.blocks(), keyMsg.String()) {
+				cmds = append(cmds, a.loadOlderCmd())
+			} else {
 				a.input, tiCmd = a.input.Update(msg)
 			}
 		} else {
-			a.viewport, vpCmd = a.viewport.Update(msg)
 			a.input, tiCmd = a.input.Update(msg)
 		}
-		cmds = append(cmds, vpCmd, tiCmd)
+		cmds = append(cmds, tiCmd)
 	}
 
 	return a, tea.Batch(cmds...)
@@ -325,17 +660,20 @@ func (a *App) handleKey(msg tea.KeyMsg) tea.Cmd {
 	case "ctrl+s":
 		return a.openPickerCmd()
 	case "enter":
+		// Read-only mode still takes client commands, even offline.
+		if a.inputLocked() && !a.cfg.ReadOnly {
+			return nil
+		}
 		text := strings.TrimSpace(a.input.Value())
-		if text == "" {
+		if text == "" || (a.cfg.ReadOnly && !strings.HasPrefix(text, "/")) {
 			return nil
 		}
 		a.input.Reset()
+		a.chat.gotoBottom()
 		if strings.HasPrefix(text, "/") {
 			return a.handleSlash(text)
 		}
-		a.isWaiting = true
-		a.appendMsg(a.renderMessage("user", text, time.Now()))
-		return a.sendCmd(text)
+		return a.submit(text)
 	}
 	return nil
 }
@@ -347,24 +685,29 @@ func (a *App) handleSlash(cmd string) tea.Cmd {
 		return tea.Quit
 	case "/clear":
 		a.messages = nil
-		a.flushViewport()
+		a.olderCursor = "" // older pages would reappear above an empty chat
+		a.chat.gotoBottom()
+		a.flushTranscript()
 		return nil
 	case "/help":
-		a.appendMsg(renderMsg{
-			rendered: styleSystemMsg.Render(
-				"Client: /clear  /sessions  /quit\n" +
-					"Gateway: /model  /models  /status  /stop  /thinking  /verbose  /compact  /reset  /new\n" +
-					"Scroll: ↑↓ PgUp PgDn  │  Switch session: ctrl+s",
-			),
-		})
+		a.appendMsg(noteMsg(styleSystemMsg,
+			"Client: /clear  /sessions  /diag  /quit\n"+
+				"Gateway: /model  /models  /status  /stop  /thinking  /verbose  /compact  /reset  /new\n"+
+				"Scroll: ↑↓ PgUp PgDn  │  Switch session: ctrl+s",
+		))
 		return nil
 	case "/sessions":
 		return a.openPickerCmd()
+	case "/diag":
+		a.showDiag = true
+		return nil
 	default:
+		if a.cfg.ReadOnly {
+			a.appendMsg(noteMsg(styleHint, "⚠ read-only: "+strings.Fields(cmd)[0]+" was not sent"))
+			return nil
+		}
 		// Forward to gateway — it handles /model, /stop, /thinking, /status, etc.
-		a.isWaiting = true
-		a.appendMsg(a.renderMessage("user", cmd, time.Now()))
-		return a.sendCmd(cmd)
+		return a.submit(cmd)
 	}
 }
 
@@ -372,44 +715,368 @@ func (a *App) handleChatEvent(ev gateway.ChatEvent) tea.Cmd {
 	if ev.SessionKey != "" && ev.SessionKey != a.sessionKey {
 		return nil
 	}
+	if ev.Role == "user" {
+		a.addPrompt(ev)
+		return nil
+	}
+	if ev.State == "delta" && a.runFinished(ev.RunID) {
+		// Queued before the final, which came by the inbox.
+		return nil
+	}
+	// Another client's run says nothing about ours. Until the send returns
+	// our run ID, any run may be ours.
+	if a.localRunID == "" || ev.RunID == a.localRunID {
+		a.isWaiting = false
+	}
 	switch ev.State {
 	case "delta":
-		a.isWaiting = false
-		a.streamRunID = ev.RunID
-		a.streamBuf = ev.Content
-		a.flushViewport()
-	case "final":
-		a.isWaiting = false
+		a.liveStream(ev.RunID).buf = ev.Content
+		return a.requestFrame()
+	case "final", "aborted":
+		// An aborted run keeps what it had streamed.
 		content := ev.Content
 		if content == "" {
-			content = a.streamBuf
+			content = a.endStream(ev.RunID)
+		} else {
+			a.endStream(ev.RunID)
 		}
-		a.streamBuf = ""
-		a.streamRunID = ""
 		if content != "" {
-			a.appendMsg(a.renderMessage("assistant", content, time.Now()))
+			m := chatMsg("assistant", content, eventTime(ev))
+			m.id = ev.MessageID
+			a.upsertMsg(m)
 		}
-		// If this run was triggered by another client, reload history to show their message
-		if ev.RunID != "" && ev.RunID != a.localRunID {
-			a.localRunID = "" // clear so next external run also triggers reload
-			return a.reloadHistoryCmd()
+		// A run started by another client whose prompt was not sent as an
+		// event: fetch it from recent history.
+		external := ev.RunID != "" && ev.RunID != a.localRunID && ev.RunID != a.promptRun
+		a.finishRun(ev.RunID)
+		if external {
+			return tea.Batch(a.syncHistoryCmd(), a.refreshSessionCmd())
 		}
-		a.localRunID = ""
+		return a.refreshSessionCmd()
 	case "error":
-		a.isWaiting = false
-		a.streamBuf = ""
-		a.streamRunID = ""
-		a.appendMsg(renderMsg{
-			rendered: styleError.Render("⚠ " + ev.ErrorMsg),
-		})
+		a.endStream(ev.RunID)
+		a.finishRun(ev.RunID)
+		a.appendMsg(noteMsg(styleError, "⚠ "+ev.ErrorMsg))
 	}
 	return nil
 }
 
-func (a *App) sendCmd(text string) tea.Cmd {
+// finishedRunsKept bounds the finished run IDs remembered by finishRun.
+const finishedRunsKept = 16
+
+// finishRun records that runID is over, so a late delta or send reply for
+// it cannot bring it back.
+func (a *App) finishRun(runID string) {
+	if runID == "" {
+		return
+	}
+	if runID == a.localRunID {
+		a.localRunID = ""
+	}
+	a.finishedRuns = append(a.finishedRuns, runID)
+	if n := len(a.finishedRuns); n > finishedRunsKept {
+		a.finishedRuns = a.finishedRuns[n-finishedRunsKept:]
+	}
+}
+
+func (a *App) runFinished(runID string) bool {
+	return runID != "" && slices.Contains(a.finishedRuns, runID)
+}
+
+// liveStream is a reply still streaming. Runs from several clients can
+// stream at once, so each has its own.
+type liveStream struct {
+	runID  string
+	buf    string
+	render streamRenderer // incremental rendering of buf
+}
+
+// liveStream returns the stream for runID, starting one if needed.
+func (a *App) liveStream(runID string) *liveStream {
+	for _, s := range a.streams {
+		if s.runID == runID {
+			return s
+		}
+	}
+	s := &liveStream{runID: runID}
+	a.streams = append(a.streams, s)
+	return s
+}
+
+// endStream drops the stream for runID and returns what it had received.
+func (a *App) endStream(runID string) string {
+	for i, s := range a.streams {
+		if s.runID == runID {
+			a.streams = append(a.streams[:i], a.streams[i+1:]...)
+			return s.buf
+		}
+	}
+	return ""
+}
+
+func (a *App) handlePromptKey(msg tea.KeyMsg) tea.Cmd {
+	p := a.prompt
+	answer := func(r promptReply) {
+		p.reply <- r
+		a.prompt = nil
+		a.promptInput.Blur()
+	}
+
+	if p.hostKey {
+		switch msg.String() {
+		case "y":
+			answer(promptReply{answer: "yes", ok: true})
+		case "n", "esc":
+			answer(promptReply{answer: "no", ok: true})
+		}
+		return nil
+	}
+
+	switch msg.String() {
+	case "enter":
+		answer(promptReply{answer: a.promptInput.Value(), ok: true})
+		return nil
+	case "esc":
+		answer(promptReply{})
+		return nil
+	}
+	var cmd tea.Cmd
+	a.promptInput, cmd = a.promptInput.Update(msg)
+	return cmd
+}
+
+// approvalGrace is how long keys are ignored after an approval request is
+// shown, so keys typed for the chat input don't answer it.
+var approvalGrace = 750 * time.Millisecond
+
+// handleApprovalKey answers the request at the head of the queue. There is
+// deliberately no Enter binding, and "a" must be pressed twice: both are too
+// easy to hit by accident for a decision that runs a command.
+func (a *App) handleApprovalKey(msg tea.KeyMsg) tea.Cmd {
+	if !a.approvalsEnabled() {
+		return nil
+	}
+	if msg.String() == "ctrl+c" {
+		a.cleanup()
+		return tea.Quit
+	}
+	a.updateApprovals(nil)
+	if len(a.approvals) == 0 || a.approvals[0].ID == a.approvalBusy || time.Since(a.approvalAt) < approvalGrace {
+		return nil
+	}
+	confirming := a.confirmAlways
+	a.confirmAlways = false
+	var decision gateway.ApprovalDecision
+	switch msg.String() {
+	case "a":
+		if !confirming {
+			a.confirmAlways = true
+			return nil
+		}
+		decision = gateway.ApproveAlways
+	case "y":
+		if confirming {
+			return nil
+		}
+		decision = gateway.ApproveOnce
+	case "n", "d":
+		if confirming {
+			return nil
+		}
+		decision = gateway.ApprovalDeny
+	default:
+		return nil
+	}
+	// The request stays queued until the gateway has the answer, so a
+	// failed resolve can be retried.
+	req := a.approvals[0]
+	a.approvalBusy = req.ID
+	client := a.client
+	return func() tea.Msg {
+		return approvalDoneMsg{id: req.ID, err: client.ResolveApproval(req.ID, decision)}
+	}
+}
+
+// updateApprovals applies change to the queue, drops requests that have
+// expired and, when a different request comes to the head, restarts the
+// grace period for it.
+func (a *App) updateApprovals(change func()) {
+	head := func() string {
+		if len(a.approvals) == 0 {
+			return ""
+		}
+		return a.approvals[0].ID
+	}
+	prev := head()
+	if change != nil {
+		change()
+	}
+	now := time.Now()
+	live := a.approvals[:0]
+	for _, r := range a.approvals {
+		if r.ExpiresAt.IsZero() || r.ExpiresAt.After(now) || r.ID == a.approvalBusy {
+			live = append(live, r)
+		}
+	}
+	a.approvals = live
+	if id := head(); id != "" && id != prev {
+		a.approvalAt = now
+		a.confirmAlways = false
+	}
+}
+
+// dropApproval removes a request that has been answered, here or by
+// another client.
+func (a *App) dropApproval(id string) {
+	for i, r := range a.approvals {
+		if r.ID == id {
+			a.approvals = append(a.approvals[:i], a.approvals[i+1:]...)
+			return
+		}
+	}
+}
+
+// approvalsEnabled reports whether this client answers exec approvals. The
+// gateway may broadcast requests to clients without the scope; those are
+// ignored rather than shown as a modal that cannot be answered.
+func (a *App) approvalsEnabled() bool {
+	return a.cfg.Approvals && !a.cfg.ReadOnly
+}
+
+// applyApproval adds or removes a request for an approval event.
+func (a *App) applyApproval(msg tea.Msg) {
+	if !a.approvalsEnabled() {
+		return
+	}
+	switch msg := msg.(type) {
+	case approvalRequestMsg:
+		a.approvals = append(a.approvals, gateway.ApprovalRequest(msg))
+	case approvalResolvedMsg:
+		a.dropApproval(msg.id)
+	}
+}
+
+// drainInbox handles the events waiting in the inbox, in the order they
+// arrived.
+func (a *App) drainInbox() tea.Cmd {
+	var cmds []tea.Cmd
+	a.updateApprovals(func() {
+		for _, msg := range a.inbox.take() {
+			if ev, ok := msg.(chatEventMsg); ok {
+				cmds = append(cmds, a.handleChatEvent(gateway.ChatEvent(ev)))
+				continue
+			}
+			a.applyApproval(msg)
+		}
+	})
+	return tea.Batch(cmds...)
+}
+
+// eventInbox carries gateway events that must not be dropped from the read
+// loop to Update. Unlike the events channel it has no fixed capacity, so
+// pushing never blocks the read loop.
+type eventInbox struct {
+	mu   sync.Mutex
+	msgs []tea.Msg
+}
+
+func (b *eventInbox) push(msg tea.Msg) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.msgs = append(b.msgs, msg)
+}
+
+func (b *eventInbox) take() []tea.Msg {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msgs := b.msgs
+	b.msgs = nil
+	return msgs
+}
+
+// submit shows text as the user's message and sends it. While the gateway
+// is on its way back the message is queued instead, and sent on reconnect.
+func (a *App) submit(text string) tea.Cmd {
+	m := chatMsg("user", text, time.Now())
+	if a.connStatus != gateway.StatusConnected {
+		m.queued = true
+		a.queue = append(a.queue, queuedSend{sessionKey: a.sessionKey, text: text})
+		a.appendMsg(m)
+		return nil
+	}
+	a.isWaiting = true
+	a.localRunID = "" // until the send returns the new run's ID
+	a.appendMsg(m)
+	return a.sendCmd(a.sessionKey, text)
+}
+
+// queuedSend is a message typed while offline, bound to the session it was
+// typed in.
+type queuedSend struct {
+	sessionKey string
+	text       string
+}
+
+// flushQueue sends the messages typed while offline, in order.
+func (a *App) flushQueue() tea.Cmd {
+	if len(a.queue) == 0 {
+		return nil
+	}
+	cmds := make([]tea.Cmd, len(a.queue))
+	for i, q := range a.queue {
+		cmds[i] = a.sendCmd(q.sessionKey, q.text)
+	}
+	a.queue = nil
+	for i := range a.messages {
+		if m := &a.messages[i]; m.queued {
+			m.queued = false
+			m.cur, m.prev = rendering{}, rendering{}
+		}
+	}
+	a.isWaiting = true
+	a.localRunID = ""
+	a.flushTranscript()
+	return tea.Sequence(cmds...)
+}
+
+const (
+	placeholderOnline   = "Type a message…"
+	placeholderQueued   = "Reconnecting — messages are sent once the gateway is back"
+	placeholderOffline  = "Disconnected — sending is disabled"
+	placeholderReadOnly = "Read-only — only client commands like /sessions, /diag and /quit run"
+)
+
+// inputLocked reports whether the gateway is gone for good, so there is
+// nothing to send to or queue for.
+func (a *App) inputLocked() bool {
+	return a.connStatus == gateway.StatusError || a.connStatus == gateway.StatusDisconnected
+}
+
+// syncInput matches the input box to the connection: locked when it is
+// gone, queueing while it comes back.
+func (a *App) syncInput() {
+	if a.cfg.ReadOnly {
+		return
+	}
+	switch {
+	case a.inputLocked():
+		a.input.Placeholder = placeholderOffline
+		a.input.Blur()
+	case a.connStatus == gateway.StatusConnected:
+		a.input.Placeholder = placeholderOnline
+		a.input.Focus()
+	default:
+		a.input.Placeholder = placeholderQueued
+		a.input.Focus()
+	}
+}
+
+func (a *App) sendCmd(sessionKey, text string) tea.Cmd {
+	if a.cfg.ReadOnly {
+		return nil
+	}
 	a.msgSeq++
 	key := fmt.Sprintf("cli-%d-%d", time.Now().UnixMilli(), a.msgSeq)
-	sessionKey := a.sessionKey
 	client := a.client
 	return func() tea.Msg {
 		runID, err := client.SendMessage(sessionKey, text, key)
@@ -424,7 +1091,7 @@ func (a *App) reloadHistoryCmd() tea.Cmd {
 	sessionKey := a.sessionKey
 	client := a.client
 	return func() tea.Msg {
-		history, err := client.GetHistory(sessionKey, 50)
+		history, err := client.GetHistory(sessionKey, a.cfg.HistoryPageSize(), "")
 		if err != nil {
 			return nil
 		}
@@ -444,8 +1111,20 @@ func (a *App) openPickerCmd() tea.Cmd {
 }
 
 func (a *App) handlePickerKey(msg tea.KeyMsg) tea.Cmd {
+	if a.pickerAction != pickNone {
+		return a.handlePickerActionKey(msg)
+	}
+	a.pickerErr = ""
 	switch msg.String() {
-	case "ctrl+c", "esc", "q":
+	case "ctrl+c":
+		a.cleanup()
+		return tea.Quit
+	case "esc", "q":
+		if a.sessionKey == "" {
+			// Picking the session to start with; there is no chat to return to.
+			a.cleanup()
+			return tea.Quit
+		}
 		a.state = stateChat
 	case "up", "k":
 		if a.pickerIdx > 0 {
@@ -455,6 +1134,14 @@ func (a *App) handlePickerKey(msg tea.KeyMsg) tea.Cmd {
 		if a.pickerIdx < len(a.sessions)-1 {
 			a.pickerIdx++
 		}
+	case "n":
+		a.beginPickerAction(pickNew)
+	case "r":
+		a.beginPickerAction(pickRename)
+	case "R":
+		a.beginPickerAction(pickReset)
+	case "d":
+		a.beginPickerAction(pickDelete)
 	case "enter":
 		if len(a.sessions) > 0 {
 			selected := a.sessions[a.pickerIdx]
@@ -472,12 +1159,14 @@ func (a *App) switchSessionCmd(s gateway.Session) tea.Cmd {
 	a.sessionKey = s.Key
 	a.session = s
 	a.messages = nil
-	a.streamBuf = ""
-	a.streamRunID = ""
+	a.streams = nil
 	a.localRunID = ""
+	a.olderCursor = ""
+	a.loadingOlder = false
 	a.isWaiting = false
 	a.state = stateChat
-	a.flushViewport()
+	a.chat.gotoBottom()
+	a.flushTranscript()
 	return a.reloadHistoryCmd()
 }
 
@@ -509,20 +1198,80 @@ func (a *App) viewSessionPicker() string {
 		rows = append(rows, styleHelp.Render("  No sessions available"))
 	}
 
-	hint := styleTimestamp.Render("↑↓: navigate   enter: select   esc: cancel")
-	body := lipgloss.JoinVertical(lipgloss.Left,
-		title,
-		"",
-		strings.Join(rows, "\n"),
-		"",
-		hint,
-	)
+	hint := styleTimestamp.Render("↑↓: navigate   enter: select   esc: back   ctrl+c: quit\n" +
+		"n: new   r: rename   R: reset   d: delete")
+	parts := []string{title, "", strings.Join(rows, "\n"), ""}
+	if a.pickerAction != pickNone {
+		parts = append(parts, a.viewPickerAction(), "")
+	} else {
+		if a.pickerErr != "" {
+			parts = append(parts, styleError.Render("⚠ "+a.pickerErr), "")
+		}
+		parts = append(parts, hint)
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left, parts...)
 
 	box := styleConnectBox.Width(min(60, a.width-8)).Render(body)
 	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
 }
 
+func (a *App) handleDiagKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		a.cleanup()
+		return tea.Quit
+	case "esc", "q", "enter":
+		a.showDiag = false
+	}
+	return nil
+}
 
+// viewDiagnostics shows the tunnel's state, the latest diagnosis and as much
+// of its recent output as fits.
+func (a *App) viewDiagnostics() string {
+	width := min(100, a.width-8)
+	title := styleApprovalTitle.Render("  Tunnel diagnostics  ")
+
+	var status string
+	switch {
+	case a.tun != nil:
+		status = styleTimestamp.Render(fmt.Sprintf("%-8s", "state")) +
+			styleMessageBody.Render(a.tun.Name()+" "+string(a.tunState)+", local port "+strconv.Itoa(a.tun.LocalPort))
+	default:
+		status = styleTimestamp.Render("No tunnel configured.")
+	}
+	rows := []string{title, "", status}
+	if path := a.cfg.LogPath(); path != "" {
+		rows = append(rows, styleTimestamp.Render(fmt.Sprintf("%-8s", "log"))+styleMessageBody.Render(path))
+	}
+	if hint := a.diag.Problem(); hint != "" {
+		rows = append(rows, "", styleHint.Render("→ "+hint))
+	}
+	rows = append(rows, "")
+
+	// Leave room for the box chrome, the rows above and the help line.
+	lines := a.diag.Lines()
+	room := max(1, a.height-len(rows)-8)
+	if len(lines) > room {
+		lines = lines[len(lines)-room:]
+	}
+	if len(lines) == 0 {
+		rows = append(rows, styleHelp.Render("Nothing logged yet."))
+	}
+	for _, l := range lines {
+		ts := styleTimestamp.Render(l.Time.Format("15:04:05") + " ")
+		rows = append(rows, ts+styleMessageBody.Render(truncate(l.Text, width-16)))
+	}
+	rows = append(rows, "", styleTimestamp.Render("esc: close"))
+
+	box := styleConnectBox.Width(width).Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// truncate shortens s to at most width cells, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	return ansi.Truncate(s, max(width, 1), "…")
+}
 
 // ── View ──────────────────────────────────────────────────────────────────────
 
@@ -534,6 +1283,15 @@ func (a *App) View() string {
 	case stateConnecting:
 		return a.viewConnecting()
 	case stateChat:
+		if a.prompt != nil {
+			return a.viewPrompt()
+		}
+		if len(a.approvals) > 0 {
+			return a.viewApproval()
+		}
+		if a.showDiag {
+			return a.viewDiagnostics()
+		}
 		return a.viewChat()
 	case stateSessionPicker:
 		return a.viewSessionPicker()
@@ -544,17 +1302,28 @@ func (a *App) View() string {
 }
 
 func (a *App) viewConnecting() string {
-	var statusLine string
+	if a.prompt != nil {
+		return a.viewPrompt()
+	}
+	var statusLines []string
 	if a.cfg.SSHEnabled() {
-		statusLine = fmt.Sprintf("%s Establishing SSH tunnel to %s…", a.spin.View(), a.cfg.SSH.Host)
+		statusLines = append(statusLines,
+			fmt.Sprintf("%s Establishing SSH tunnel to %s…", a.spin.View(), a.cfg.SSH.Host),
+			styleTimestamp.Render("forwarding to "+a.cfg.SSH.RemoteAddr()+" from "+a.cfg.SSH.Host),
+		)
+	} else if a.cfg.TunnelEnabled() {
+		statusLines = append(statusLines,
+			fmt.Sprintf("%s Starting tunnel…", a.spin.View()),
+			styleTimestamp.Render(a.cfg.Tunnel.Command),
+		)
 	} else {
-		statusLine = fmt.Sprintf("%s Connecting to %s…", a.spin.View(), a.cfg.GatewayURL)
+		statusLines = append(statusLines, fmt.Sprintf("%s Connecting to %s…", a.spin.View(), a.cfg.GatewayURL))
 	}
 
 	content := lipgloss.JoinVertical(lipgloss.Center,
 		styleAppTitle.Render("🦀 ClawChat CLI"),
 		"",
-		statusLine,
+		strings.Join(statusLines, "\n"),
 		"",
 		styleHelp.Render("ctrl+c to quit"),
 	)
@@ -562,15 +1331,128 @@ func (a *App) viewConnecting() string {
 	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
 }
 
+func (a *App) viewPrompt() string {
+	p := a.prompt
+	width := min(72, a.width-8)
+
+	var body string
+	if p.hostKey {
+		body = lipgloss.JoinVertical(lipgloss.Left,
+			styleApprovalTitle.Render("  Unknown SSH host  "),
+			"",
+			styleMessageBody.Render("The authenticity of "+p.title+" can't be established."),
+			"",
+			styleTimestamp.Render("fingerprint ")+styleMessageBody.Render(p.text),
+			"",
+			styleTimestamp.Render("y: trust and remember   n: reject"),
+		)
+	} else {
+		body = lipgloss.JoinVertical(lipgloss.Left,
+			styleAppTitle.Render("SSH"),
+			"",
+			lipgloss.NewStyle().Width(width-8).Render(styleMessageBody.Render(p.text)),
+			"",
+			a.promptInput.View(),
+			"",
+			styleTimestamp.Render("enter: submit   esc: cancel"),
+		)
+	}
+
+	box := styleConnectBox.Width(width).Render(body)
+	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
+}
+
 func (a *App) viewError() string {
-	content := lipgloss.JoinVertical(lipgloss.Left,
+	width := min(80, a.width-8)
+	rows := []string{
 		styleError.Render("Connection Error"),
 		"",
 		fmt.Sprintf("%v", a.err),
+	}
+	if hint := tunnel.Explain(a.err); hint != "" {
+		rows = append(rows, "", styleHint.Render("→ "+hint))
+	}
+	// The last few tunnel lines usually say why it failed.
+	if lines := a.diag.Lines(); len(lines) > 0 {
+		rows = append(rows, "", styleTimestamp.Render("Tunnel log:"))
+		for _, l := range lines[max(0, len(lines)-6):] {
+			rows = append(rows, styleTimestamp.Render(truncate(l.Text, width-8)))
+		}
+	}
+	switch {
+	case a.checking:
+		rows = append(rows, "", styleTimestamp.Render("Running diagnostics…"))
+	case a.checks != nil:
+		rows = append(rows, "", styleTimestamp.Render("Diagnostics:"))
+		for _, c := range a.checks {
+			name := fmt.Sprintf("%-8s", c.name)
+			if c.err != nil {
+				rows = append(rows, styleError.Render("✕ "+name)+styleMessageBody.Render(truncate(c.err.Error(), width-16)))
+				if hint := tunnel.Explain(c.err); hint != "" {
+					rows = append(rows, styleHint.Render("  → "+hint))
+				}
+				continue
+			}
+			if c.skipped {
+				rows = append(rows, styleTimestamp.Render("– "+name+truncate(c.detail, width-16)))
+				continue
+			}
+			rows = append(rows, styleBadgeConnected.Render("✓ "+name)+styleTimestamp.Render(truncate(c.detail, width-16)))
+		}
+	}
+	help := "r: retry   e: edit config   d: diagnose   q: quit"
+	if a.failed.client != nil {
+		help = "s: pick a session   " + help
+	}
+	rows = append(rows, "", styleHelp.Render(help))
+	content := lipgloss.JoinVertical(lipgloss.Left, rows...)
+	box := styleConnectBox.Width(width).Render(content)
+	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+func (a *App) viewApproval() string {
+	req := a.approvals[0]
+
+	title := "  Exec approval  "
+	if n := len(a.approvals); n > 1 {
+		title = fmt.Sprintf("  Exec approval (1 of %d)  ", n)
+	}
+
+	field := func(name, value string) string {
+		if value == "" {
+			value = "—"
+		}
+		return styleTimestamp.Render(fmt.Sprintf("%-10s", name)) + styleMessageBody.Render(value)
+	}
+	rows := []string{
+		field("command", req.Command),
+		field("cwd", req.Cwd),
+		field("agent", req.AgentID),
+	}
+	if req.Host != "" {
+		rows = append(rows, field("host", req.Host))
+	}
+	if !req.ExpiresAt.IsZero() {
+		rows = append(rows, field("expires", req.ExpiresAt.Format("15:04:05")))
+	}
+
+	width := min(80, a.width-8)
+	hint := styleTimestamp.Render("y: approve once   a: approve always   n: deny")
+	switch {
+	case a.approvalBusy == req.ID:
+		hint = styleTimestamp.Render("sending…")
+	case a.confirmAlways:
+		hint = styleError.Render("press a again to always allow this command, any other key to go back")
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		styleApprovalTitle.Render(title),
+		"",
+		lipgloss.NewStyle().Width(width-8).Render(strings.Join(rows, "\n")),
 		"",
-		styleHelp.Render("Press any key to quit."),
+		hint,
 	)
-	box := styleConnectBox.Width(60).Render(content)
+
+	box := styleConnectBox.Width(width).Render(body)
 	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, box)
 }
 
@@ -580,10 +1462,23 @@ func (a *App) viewChat() string {
 	}
 
 	header := a.renderHeader()
-	chatBox := styleChatBox.Width(a.width - 2).Render(a.viewport.View())
-	inputBox := styleInputBoxFocused.Width(a.width - 2).Render(a.input.View())
-	help := styleHelp.Padding(0, 1).Render("enter: send   ctrl+s: sessions   ctrl+c: quit   /help   ↑↓: scroll")
+	chatBox := a.viewChatBox()
+	inputStyle := styleInputBoxFocused
+	helpText := "enter: send   ctrl+s: sessions   ctrl+c: quit   /help   ↑↓: scroll"
+	if a.cfg.ReadOnly {
+		inputStyle = styleInputBoxDisabled
+		helpText = "read-only   /sessions  /diag  /quit   ctrl+s: sessions   ↑↓: scroll"
+	}
+	if a.inputLocked() && !a.cfg.ReadOnly {
+		inputStyle = styleInputBoxDisabled
+		helpText = "offline   ctrl+s: sessions   ctrl+c: quit   ↑↓: scroll"
+	}
+	inputBox := inputStyle.Width(a.width - 2).Render(a.input.View())
+	help := styleHelp.Padding(0, 1).Render(helpText)
 
+	if bar := a.viewStatusBar(); bar != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, header, chatBox, inputBox, help, bar)
+	}
 	return lipgloss.JoinVertical(lipgloss.Left, header, chatBox, inputBox, help)
 }
 
@@ -591,12 +1486,30 @@ func (a *App) renderHeader() string {
 	left := styleAppTitle.Render("🦀 ClawChat CLI")
 
 	var badges []string
+	if a.cfg.ReadOnly {
+		badges = append(badges, styleBadgeReadOnly.Render(" read-only "))
+	}
 	if a.tun != nil {
-		badges = append(badges, styleBadgeSSH.Render(" SSH "))
+		name := a.tun.Name()
+		switch a.tunState {
+		case tunnel.StateReconnecting:
+			badges = append(badges, styleBadgeSSHDegraded.Render(" "+name+" ↻ "))
+		case tunnel.StateDown:
+			badges = append(badges, styleBadgeSSHDown.Render(" "+name+" ✕ "))
+		default:
+			badges = append(badges, styleBadgeSSH.Render(" "+name+" "))
+		}
 	}
-	if a.client != nil && a.client.Status() == gateway.StatusConnected {
+	switch a.connStatus {
+	case gateway.StatusConnected:
 		badges = append(badges, styleBadgeConnected.Render("● connected"))
-	} else {
+	case gateway.StatusReconnecting:
+		badges = append(badges, styleBadgeReconnecting.Render("↻ reconnecting"))
+	case gateway.StatusHandshaking:
+		badges = append(badges, styleBadgeConnecting.Render("◌ handshaking"))
+	case gateway.StatusError, gateway.StatusDisconnected:
+		badges = append(badges, styleBadgeDisconnected.Render("✕ disconnected"))
+	default:
 		badges = append(badges, styleBadgeConnecting.Render("○ connecting"))
 	}
 
@@ -635,8 +1548,8 @@ func (a *App) rebuildLayout() {
 	if a.width == 0 || a.height == 0 {
 		return
 	}
-	// header(1) + chatBox(border 2 + viewport) + inputBox(border 2 + content 3) + help(1)
-	vpHeight := a.height - 9
+	// header(1) + chatBox(border 2 + transcript) + inputBox(border 2 + content 3) + help(1) + status bar
+	vpHeight := a.height - 9 - a.statusBarHeight()
 	if vpHeight < 3 {
 		vpHeight = 3
 	}
@@ -647,101 +1560,317 @@ func (a *App) rebuildLayout() {
 	}
 
 	if !a.ready {
-		a.viewport = viewport.New(vpWidth, vpHeight)
+		a.chat = newTranscript(vpWidth, vpHeight)
 	} else {
-		a.viewport.Width = vpWidth
-		a.viewport.Height = vpHeight
+		a.chat.setSize(vpWidth, vpHeight)
 	}
 	a.ready = true
 	// Input width: border(2) + padding(2) = 4 total overhead
 	a.input.SetWidth(a.width - 6)
 
-	a.flushViewport()
+	a.flushTranscript()
 }
 
-func (a *App) flushViewport() {
+// flushTranscript brings the live block up to date. The transcript stays
+// where it is: at the bottom if it was following, otherwise on whatever
+// the user scrolled back to. Only the stream is rendered here; messages are
+// rendered when they scroll into view.
+func (a *App) flushTranscript() {
 	if !a.ready {
 		return
 	}
 
-	var blocks []string
-	for _, m := range a.messages {
-		blocks = append(blocks, m.rendered)
+	label := styleAssistantLabel.Render("assistant")
+	var parts []string
+	for _, s := range a.streams {
+		if s.buf == "" {
+			continue
+		}
+		parts = append(parts, "", label, s.render.render(a.markdown(), s.buf)+"▌")
 	}
+	if a.isWaiting {
+		parts = append(parts, "", label, styleHelp.Render("thinking…"))
+	}
+	a.streamView = ""
+	if len(parts) > 0 {
+		a.streamView = lipgloss.JoinVertical(lipgloss.Left, parts...)
+	}
+	a.frames.drawn()
+}
 
-	if a.isWaiting && a.streamBuf == "" {
-		label := styleAssistantLabel.Render("assistant")
-		thinking := lipgloss.JoinVertical(lipgloss.Left,
-			"",
-			label,
-			styleHelp.Render("thinking…"),
-		)
-		blocks = append(blocks, thinking)
-	} else if a.streamBuf != "" {
-		label := styleAssistantLabel.Render("assistant")
-		// Use lipgloss width-constrained style for wrapping
-		content := lipgloss.NewStyle().Width(a.viewport.Width - 2).Render(a.streamBuf)
-		streaming := lipgloss.JoinVertical(lipgloss.Left,
-			"",
-			label,
-			content+"▌",
-		)
-		blocks = append(blocks, streaming)
+// viewChatBox draws the transcript pane. While the user is scrolled back,
+// the bottom border says what has arrived below.
+func (a *App) viewChatBox() string {
+	body := a.chat.view(a.blocks())
+	style := styleChatBox.Width(a.width - 2)
+	note := a.newBelowNote()
+	if note != "" {
+		style = style.BorderBottom(false)
+	}
+	if a.loadingOlder {
+		style = style.BorderTop(false)
+	}
+	box := style.Render(body)
+	if a.loadingOlder {
+		box = topBorder(lipgloss.Width(box), "↑ loading older messages…") + "\n" + box
 	}
+	if note != "" {
+		box += "\n" + bottomBorder(lipgloss.Width(box), note)
+	}
+	return box
+}
+
+// newBelowNote is the jump-down hint for the chat box border, or "" when
+// following or nothing new has arrived.
+func (a *App) newBelowNote() string {
+	if a.chat.follow {
+		return ""
+	}
+	switch n := a.chat.unseen; {
+	case n == 1:
+		return "↓ 1 new message · end to jump"
+	case n > 1:
+		return fmt.Sprintf("↓ %d new messages · end to jump", n)
+	case a.streamView != "":
+		return "↓ reply in progress · end to jump"
+	}
+	return ""
+}
+
+// bottomBorder draws the chat box's bottom edge, width columns wide, with
+// label set into it.
+func bottomBorder(width int, label string) string {
+	b := lipgloss.RoundedBorder()
+	return labelledBorder(width, label, b.BottomLeft, b.Bottom, b.BottomRight)
+}
+
+// topBorder is the chat box's top edge with label set into it.
+func topBorder(width int, label string) string {
+	b := lipgloss.RoundedBorder()
+	return labelledBorder(width, label, b.TopLeft, b.Top, b.TopRight)
+}
+
+func labelledBorder(width int, label, left, edge, right string) string {
+	style := lipgloss.NewStyle().Foreground(colorBorder)
+	label = ansi.Truncate(label, max(width-6, 0), "…")
+	fill := max(width-5-lipgloss.Width(label), 0)
+	return style.Render(left+edge+" ") +
+		styleNewBelow.Render(label) +
+		style.Render(" "+strings.Repeat(edge, fill)+right)
+}
+
+// chatBlocks presents the messages and the live block to the transcript.
+type chatBlocks App
+
+func (a *App) blocks() blocks { return (*chatBlocks)(a) }
+
+func (c *chatBlocks) count() int {
+	if c.streamView != "" {
+		return len(c.messages) + 1
+	}
+	return len(c.messages)
+}
+
+func (c *chatBlocks) block(i int) string {
+	if i == len(c.messages) {
+		return c.streamView
+	}
+	return (*App)(c).view(&c.messages[i])
+}
+
+func (c *chatBlocks) blockHeight(i int) int {
+	if i == len(c.messages) {
+		return lipgloss.Height(c.streamView)
+	}
+	(*App)(c).view(&c.messages[i])
+	return c.messages[i].cur.height
+}
 
-	a.viewport.SetContent(strings.Join(blocks, "\n"))
-	a.viewport.GotoBottom()
+// msgWidth is the width messages are wrapped to.
+func (a *App) msgWidth() int {
+	return max(a.chat.width-2, 10)
 }
 
-func (a *App) renderMessage(role, content string, ts time.Time) renderMsg {
+// markdown returns the assistant message renderer for the current width.
+// The one for the previous width is kept alongside, like message renders.
+func (a *App) markdown() *markdown {
+	width := a.msgWidth()
+	switch {
+	case a.md != nil && a.md.width == width:
+	case a.mdPrev != nil && a.mdPrev.width == width:
+		a.md, a.mdPrev = a.mdPrev, a.md
+	default:
+		a.md, a.mdPrev = newMarkdown(width, a.dark), a.md
+	}
+	return a.md
+}
+
+// view returns m rendered for the current width, from its cache if it can.
+func (a *App) view(m *renderMsg) string {
+	switch width := a.msgWidth(); width {
+	case m.cur.width:
+	case m.prev.width:
+		m.cur, m.prev = m.prev, m.cur
+	default:
+		text := a.renderMessage(m)
+		m.cur, m.prev = rendering{width: width, height: lipgloss.Height(text), text: text}, m.cur
+	}
+	return m.cur.text
+}
+
+func (a *App) renderMessage(m *renderMsg) string {
 	tsStr := ""
-	if !ts.IsZero() {
-		tsStr = "  " + styleTimestamp.Render(ts.Format("15:04"))
+	if !m.timestamp.IsZero() {
+		tsStr = "  " + styleTimestamp.Render(m.timestamp.Format("15:04"))
 	}
 
 	// Use lipgloss Width to handle word-wrap automatically
-	msgWidth := a.viewport.Width - 2
-	if msgWidth < 10 {
-		msgWidth = 10
-	}
-	wrapped := lipgloss.NewStyle().Width(msgWidth).Render(content)
+	wrap := lipgloss.NewStyle().Width(a.msgWidth())
 
-	var label, rendered string
-	switch role {
+	switch m.role {
 	case "user":
-		label = styleUserLabel.Render("you") + tsStr
-		rendered = lipgloss.JoinVertical(lipgloss.Left, "", label, styleMessageBody.Render(wrapped))
+		label := styleUserLabel.Render("you") + tsStr
+		if m.queued {
+			label += styleTimestamp.Render(" · queued")
+		}
+		return lipgloss.JoinVertical(lipgloss.Left, "", label, styleMessageBody.Render(wrap.Render(m.content)))
 	case "assistant":
-		label = styleAssistantLabel.Render("assistant") + tsStr
-		body := styleMessageBody.Render(wrapped)
-		rendered = lipgloss.JoinVertical(lipgloss.Left, "", label, body)
+		label := styleAssistantLabel.Render("assistant") + tsStr
+		return lipgloss.JoinVertical(lipgloss.Left, "", label, a.markdown().render(m.content))
+	case "":
+		return m.style.Render(wrap.Render(m.content))
 	default:
-		return renderMsg{
-			role:      role,
-			content:   content,
-			rendered:  styleSystemMsg.Render(content),
-			timestamp: ts,
-		}
+		return styleSystemMsg.Render(wrap.Render(m.content))
 	}
+}
 
-	return renderMsg{
-		role:      role,
-		content:   content,
-		rendered:  rendered,
-		timestamp: ts,
+// noteTunnelProblem tells the user the tunnel is unhealthy, with the most
+// recent diagnosis if there is one.
+func (a *App) noteTunnelProblem() {
+	text := a.tun.Name() + " tunnel " + string(a.tunState)
+	if hint := a.diag.Problem(); hint != "" {
+		text += ": " + hint
 	}
+	a.appendMsg(noteMsg(styleHint, "⚠ "+text+" — /diag for details"))
 }
 
 func (a *App) appendMsg(m renderMsg) {
 	a.messages = append(a.messages, m)
-	a.flushViewport()
+	a.chat.added(1)
+	a.flushTranscript()
 }
 
 func (a *App) cleanup() {
-	if a.client != nil {
-		a.client.Close()
+	a.life.shutdown()
+}
+
+// Shutdown closes the gateway connection and stops the tunnel, including any
+// a connect still in progress is bringing up. It is safe to call more than
+// once, and from outside the program loop once it has exited.
+func (a *App) Shutdown() {
+	a.life.shutdown()
+}
+
+// ── Error screen ──────────────────────────────────────────────────────────────
+
+// reloadConfig is replaced in tests.
+var reloadConfig = config.Reload
+
+func (a *App) handleErrorKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c", "esc", "q":
+		a.cleanup()
+		return tea.Quit
+	case "r":
+		return a.retry()
+	case "e":
+		return editConfigCmd()
+	case "d":
+		if a.checking {
+			return nil
+		}
+		a.checking = true
+		a.checks = nil
+		return runChecks(a.cfg)
+	case "s":
+		f := a.failed
+		if f.client == nil {
+			return nil
+		}
+		a.failed = connectErrMsg{}
+		a.adopt(f.client, f.tun)
+		a.sessions = f.sessions
+		a.pickerIdx = 0
+		a.state = stateSessionPicker
+		a.rebuildLayout()
+		return statusTick()
 	}
-	if a.tun != nil {
-		a.tun.Stop()
+	return nil
+}
+
+// adopt takes over a connected client and its tunnel, if any.
+func (a *App) adopt(client *gateway.Client, tun *tunnel.Tunnel) {
+	a.client = client
+	a.connStatus = client.Status()
+	a.syncInput()
+	a.tun = tun
+	if tun != nil {
+		a.tunState = tun.State()
+	}
+}
+
+// retry leaves the error screen and connects again from scratch.
+func (a *App) retry() tea.Cmd {
+	if a.failed.client != nil {
+		a.failed.client.Close()
+	}
+	if a.failed.tun != nil {
+		a.failed.tun.Stop()
+	}
+	a.failed = connectErrMsg{}
+	a.err = nil
+	a.checks = nil
+	a.state = stateConnecting
+	return tea.Batch(a.spin.Tick, a.connectCmd())
+}
+
+// editConfigCmd suspends the UI and opens the config file in $VISUAL or
+// $EDITOR, falling back to vi.
+func editConfigCmd() tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	path := config.FilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return func() tea.Msg { return configEditedMsg{err} }
+	}
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return configEditedMsg{err} })
+}
+
+// configEdited picks up the edited config and reconnects with it. A config
+// that does not load or validate stays on the error screen, reported as the
+// error.
+func (a *App) configEdited(editErr error) tea.Cmd {
+	if editErr != nil {
+		a.err = fmt.Errorf("editor: %w", editErr)
+		return nil
+	}
+	cfg, err := reloadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		a.err = fmt.Errorf("config: %w", err)
+		a.checks = nil
+		return nil
 	}
+	a.cfg = cfg
+	a.frames = newFrameLimiter(cfg.FrameRate())
+	return a.retry()
 }
@@ -0,0 +1,455 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// newChatApp returns an App in the chat state with the given terminal size.
+func newChatApp(t testing.TB, width, height int) *App {
+	t.Helper()
+	a := New(&config.Config{}, nil)
+	a.state = stateChat
+	a.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	return a
+}
+
+// maxLineWidth draws the transcript and returns the widest message line.
+func maxLineWidth(a *App) int {
+	a.chat.view(a.blocks())
+	widest := 0
+	for _, m := range a.messages {
+		for _, line := range strings.Split(m.cur.text, "\n") {
+			widest = max(widest, ansi.StringWidth(line))
+		}
+	}
+	return widest
+}
+
+func TestResizeRewrapsMessages(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	long := strings.Repeat("word ", 40)
+	a.appendMsg(chatMsg("user", long, time.Now()))
+	a.appendMsg(chatMsg("assistant", "Some **markdown** "+long, time.Now()))
+	a.appendMsg(noteMsg(styleError, "⚠ "+long))
+
+	if w := maxLineWidth(a); w > a.msgWidth() || w < 100 {
+		t.Fatalf("at 120 columns, widest line = %d, want about %d", w, a.msgWidth())
+	}
+
+	a.Update(tea.WindowSizeMsg{Width: 50, Height: 30})
+	if w := maxLineWidth(a); w > a.msgWidth() {
+		t.Fatalf("after shrinking, widest line = %d, want <= %d", w, a.msgWidth())
+	}
+	narrow := a.messages[1].cur.text
+
+	// Going back to the previous width reuses the earlier renders.
+	a.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	if w := maxLineWidth(a); w < 100 {
+		t.Fatalf("after growing, widest line = %d, messages not rewrapped", w)
+	}
+	if a.messages[1].prev.text != narrow {
+		t.Error("render for the previous width was not kept")
+	}
+	a.messages[1].content = "changed" // a fresh render would show this
+	a.Update(tea.WindowSizeMsg{Width: 50, Height: 30})
+	a.chat.view(a.blocks())
+	if a.messages[1].cur.text != narrow {
+		t.Error("cached render not reused when returning to a width")
+	}
+}
+
+func TestScrollbackIsNotYankedByNewContent(t *testing.T) {
+	a := newChatApp(t, 80, 20)
+	for i := 0; i < 30; i++ {
+		a.appendMsg(chatMsg("user", fmt.Sprintf("message %d", i), time.Now()))
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	a.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	before := a.chat.view(a.blocks())
+
+	a.handleChatEvent(gateway.ChatEvent{State: "delta", Content: "streaming"})
+	a.Update(frameMsg{})
+	if got := a.chat.view(a.blocks()); got != before {
+		t.Fatal("window moved while a reply streamed")
+	}
+	if !strings.Contains(a.viewChatBox(), "reply in progress") {
+		t.Error("no note for the reply streaming below")
+	}
+
+	a.handleChatEvent(gateway.ChatEvent{State: "final", Content: "done"})
+	a.appendMsg(noteMsg(styleSystemMsg, "another"))
+	if got := a.chat.view(a.blocks()); got != before {
+		t.Fatal("window moved when messages were appended")
+	}
+	lines := strings.Split(a.viewChatBox(), "\n")
+	if border := lines[len(lines)-1]; !strings.Contains(border, "↓ 2 new messages") {
+		t.Errorf("bottom border = %q, want the new message count", border)
+	}
+	if w := ansi.StringWidth(lines[len(lines)-1]); w != ansi.StringWidth(lines[0]) {
+		t.Errorf("bottom border is %d wide, top is %d", w, ansi.StringWidth(lines[0]))
+	}
+
+	a.Update(tea.KeyMsg{Type: tea.KeyEnd})
+	if !a.chat.follow || a.chat.unseen != 0 {
+		t.Fatal("end did not jump to the bottom")
+	}
+	if strings.Contains(a.viewChatBox(), "new message") {
+		t.Error("note still shown at the bottom")
+	}
+	if got := a.chat.view(a.blocks()); !strings.Contains(got, "another") {
+		t.Errorf("latest message not visible after jumping:\n%s", got)
+	}
+}
+
+func TestScrollingToTheBottomResumesFollowing(t *testing.T) {
+	a := newChatApp(t, 80, 20)
+	for i := 0; i < 30; i++ {
+		a.appendMsg(chatMsg("user", fmt.Sprintf("message %d", i), time.Now()))
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyUp})
+	a.appendMsg(chatMsg("user", "new", time.Now()))
+	if a.chat.unseen != 1 {
+		t.Fatalf("unseen = %d, want 1", a.chat.unseen)
+	}
+	for i := 0; i < 10 && !a.chat.follow; i++ {
+		a.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	if !a.chat.follow || a.chat.unseen != 0 {
+		t.Fatal("scrolling down to the end did not resume following")
+	}
+	a.appendMsg(chatMsg("user", "newest", time.Now()))
+	if got := a.chat.view(a.blocks()); !strings.Contains(got, "newest") {
+		t.Error("new message not shown while following")
+	}
+}
+
+func TestHeaderFollowsGatewayStatus(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	for status, want := range map[gateway.Status]string{
+		gateway.StatusConnected:    "● connected",
+		gateway.StatusReconnecting: "↻ reconnecting",
+		gateway.StatusHandshaking:  "◌ handshaking",
+		gateway.StatusDisconnected: "✕ disconnected",
+	} {
+		a.Update(gatewayStatusMsg{status: status})
+		if header := ansi.Strip(a.renderHeader()); !strings.Contains(header, want) {
+			t.Errorf("%s: header = %q, want %q", status, header, want)
+		}
+	}
+}
+
+func TestMessagesQueueWhileReconnecting(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	a.Update(gatewayStatusMsg{status: gateway.StatusReconnecting})
+
+	a.input.SetValue("sent while offline")
+	if cmd := a.handleKey(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Fatal("message sent while reconnecting")
+	}
+	if len(a.queue) != 1 || len(a.messages) != 1 || !a.messages[0].queued {
+		t.Fatalf("queue=%d messages=%d, want one queued message", len(a.queue), len(a.messages))
+	}
+	if !strings.Contains(ansi.Strip(a.renderMessage(&a.messages[0])), "queued") {
+		t.Error("queued message is not marked")
+	}
+
+	_, cmd := a.Update(gatewayStatusMsg{status: gateway.StatusConnected})
+	if cmd == nil || len(a.queue) != 0 {
+		t.Fatal("queue not flushed on reconnect")
+	}
+	if a.messages[0].queued || !a.isWaiting {
+		t.Error("flushed message still marked queued")
+	}
+}
+
+func TestInputLockedWhileDisconnected(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	a.Update(gatewayStatusMsg{status: gateway.StatusError})
+	if a.input.Focused() {
+		t.Error("input focused while disconnected")
+	}
+	a.input.SetValue("nowhere to go")
+	if cmd := a.handleKey(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil || len(a.messages) != 0 || len(a.queue) != 0 {
+		t.Error("enter accepted a message while disconnected")
+	}
+
+	a.Update(gatewayStatusMsg{status: gateway.StatusConnected})
+	if !a.input.Focused() || a.input.Placeholder != placeholderOnline {
+		t.Error("input not unlocked on reconnect")
+	}
+}
+
+func TestStatusFromReplacedClientIgnored(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	a.client = gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.Update(gatewayStatusMsg{client: a.client, status: gateway.StatusConnected})
+
+	old := gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.Update(gatewayStatusMsg{client: old, status: gateway.StatusError})
+	if a.connStatus != gateway.StatusConnected || !a.input.Focused() {
+		t.Errorf("status from a replaced client applied: %s", a.connStatus)
+	}
+}
+
+func TestErrorScreenActions(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.Update(connectErrMsg{err: fmt.Errorf("gateway: connection refused")})
+	if a.state != stateError {
+		t.Fatal("connect error did not show the error screen")
+	}
+	view := ansi.Strip(a.View())
+	if !strings.Contains(view, "r: retry") || strings.Contains(view, "pick a session") {
+		t.Errorf("error screen help is wrong:\n%s", view)
+	}
+
+	if _, cmd := a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}); cmd != nil || a.state != stateError {
+		t.Error("an unbound key left the error screen")
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if !a.checking {
+		t.Error("d did not start diagnostics")
+	}
+	a.Update(checksDoneMsg{{name: "gateway", err: fmt.Errorf("connection refused")}})
+	if view := ansi.Strip(a.View()); !strings.Contains(view, "✕ gateway") {
+		t.Errorf("diagnostics not shown inline:\n%s", view)
+	}
+
+	if _, cmd := a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")}); cmd == nil || a.state != stateConnecting || a.checks != nil {
+		t.Error("r did not retry")
+	}
+}
+
+func TestErrorScreenReloadsEditedConfig(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.Update(connectErrMsg{err: fmt.Errorf("gateway: bad token")})
+
+	edited := &config.Config{}
+	reloadConfig = func() (*config.Config, error) { return edited, nil }
+	defer func() { reloadConfig = config.Reload }()
+
+	a.Update(configEditedMsg{})
+	if a.state != stateError || !strings.Contains(a.err.Error(), "config: gateway URL is required") {
+		t.Fatalf("invalid config: state=%v err=%v", a.state, a.err)
+	}
+
+	edited.GatewayURL, edited.Token = "ws://127.0.0.1:1", "t2"
+	if _, cmd := a.Update(configEditedMsg{}); cmd == nil || a.state != stateConnecting || a.cfg != edited {
+		t.Error("valid config was not applied and retried")
+	}
+}
+
+func TestErrorScreenPicksMissingSession(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	client := gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.Update(connectErrMsg{
+		err:      fmt.Errorf("session %q not found", "agent:main:typo"),
+		client:   client,
+		sessions: []gateway.Session{{Key: "agent:main:main"}, {Key: "agent:main:other"}},
+	})
+	if !strings.Contains(ansi.Strip(a.View()), "s: pick a session") {
+		t.Error("session picker not offered")
+	}
+
+	a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if a.state != stateSessionPicker || a.client != client || len(a.sessions) != 2 {
+		t.Fatalf("s: state=%v sessions=%d, want the picker over the kept connection", a.state, len(a.sessions))
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyDown})
+	a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if a.state != stateChat || a.sessionKey != "agent:main:other" {
+		t.Errorf("picking a session: state=%v key=%q", a.state, a.sessionKey)
+	}
+}
+
+func TestReadOnlyDisablesSending(t *testing.T) {
+	a := New(&config.Config{ReadOnly: true}, nil)
+	a.state = stateChat
+	a.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	a.Update(gatewayStatusMsg{status: gateway.StatusConnected})
+
+	if !strings.Contains(a.input.Placeholder, "Read-only") {
+		t.Errorf("placeholder = %q", a.input.Placeholder)
+	}
+	a.input.SetValue("should not send")
+	if cmd := a.handleKey(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil || len(a.messages) != 0 {
+		t.Error("enter sent a message in read-only mode")
+	}
+	if cmd := a.sendCmd(a.sessionKey, "direct"); cmd != nil {
+		t.Error("sendCmd returned a command in read-only mode")
+	}
+	a.input.SetValue("/model gpt")
+	if cmd := a.handleKey(tea.KeyMsg{Type: tea.KeyEnter}); cmd != nil {
+		t.Error("gateway slash command sent in read-only mode")
+	}
+	for _, m := range a.messages {
+		if m.role == "user" {
+			t.Errorf("read-only mode queued %q", m.content)
+		}
+	}
+}
+
+func TestReadOnlyRunsClientCommands(t *testing.T) {
+	a := New(&config.Config{ReadOnly: true}, nil)
+	a.state = stateChat
+	a.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	a.Update(gatewayStatusMsg{status: gateway.StatusDisconnected})
+
+	for _, r := range "/diag" {
+		a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	if a.input.Value() != "/diag" {
+		t.Fatalf("typed %q in read-only mode", a.input.Value())
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !a.showDiag {
+		t.Error("/diag did not open diagnostics in read-only mode")
+	}
+	a.showDiag = false
+
+	a.input.SetValue("/quit")
+	if cmd := a.handleKey(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil {
+		t.Fatal("/quit returned no command in read-only mode")
+	} else if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("/quit did not quit in read-only mode")
+	}
+}
+
+func approvalKey(a *App, key string) tea.Cmd {
+	k := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	if key == "enter" {
+		k = tea.KeyMsg{Type: tea.KeyEnter}
+	}
+	_, cmd := a.Update(k)
+	return cmd
+}
+
+func TestApprovalKeys(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.cfg.Approvals = true
+	a.Update(approvalRequestMsg{ID: "ap-1", Command: "ls"})
+	a.Update(approvalRequestMsg{ID: "ap-2", Command: "rm -rf /"})
+	if len(a.approvals) != 2 {
+		t.Fatalf("queue = %d, want 2", len(a.approvals))
+	}
+
+	// Keys are ignored right after the modal opens.
+	if cmd := approvalKey(a, "y"); cmd != nil || a.approvalBusy != "" {
+		t.Fatal("key answered the request within the grace period")
+	}
+	a.approvalAt = time.Now().Add(-time.Second)
+
+	if cmd := approvalKey(a, "enter"); cmd != nil || a.approvalBusy != "" {
+		t.Fatal("enter answered the request")
+	}
+
+	// "a" needs confirming; anything else backs out.
+	if cmd := approvalKey(a, "a"); cmd != nil || !a.confirmAlways {
+		t.Fatal("first a did not ask for confirmation")
+	}
+	if !strings.Contains(a.View(), "press a again") {
+		t.Error("confirmation hint not shown")
+	}
+	if cmd := approvalKey(a, "y"); cmd != nil || a.confirmAlways {
+		t.Fatal("y after a did not cancel the confirmation")
+	}
+	approvalKey(a, "a")
+	if cmd := approvalKey(a, "a"); cmd == nil || a.approvalBusy != "ap-1" {
+		t.Fatal("second a did not resolve the request")
+	}
+
+	// While the answer is in flight the request stays up and keys are ignored.
+	if cmd := approvalKey(a, "n"); cmd != nil || len(a.approvals) != 2 {
+		t.Fatal("key handled while resolving")
+	}
+
+	// A failed resolve keeps the request so it can be answered again.
+	a.Update(approvalDoneMsg{id: "ap-1", err: fmt.Errorf("timeout")})
+	if len(a.approvals) != 2 || a.approvals[0].ID != "ap-1" || a.approvalBusy != "" {
+		t.Fatalf("failed request not kept: %+v busy %q", a.approvals, a.approvalBusy)
+	}
+	if cmd := approvalKey(a, "n"); cmd == nil || a.approvalBusy != "ap-1" {
+		t.Fatal("retry not sent")
+	}
+	a.Update(approvalDoneMsg{id: "ap-1"})
+	if len(a.approvals) != 1 || a.approvals[0].ID != "ap-2" {
+		t.Fatalf("queue = %+v, want ap-2", a.approvals)
+	}
+
+	// The next request gets its own grace period.
+	if cmd := approvalKey(a, "y"); cmd != nil {
+		t.Error("next request answered within its grace period")
+	}
+}
+
+func TestApprovalQueueDropsResolvedAndExpired(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.cfg.Approvals = true
+	a.Update(approvalRequestMsg{ID: "ap-1"})
+	a.Update(approvalRequestMsg{ID: "ap-2", ExpiresAt: time.Now().Add(time.Hour)})
+	a.Update(approvalRequestMsg{ID: "ap-3", ExpiresAt: time.Now().Add(-time.Second)})
+	if len(a.approvals) != 2 {
+		t.Fatalf("queue = %+v, want the expired request dropped", a.approvals)
+	}
+
+	a.Update(approvalResolvedMsg{id: "ap-1"})
+	if len(a.approvals) != 1 || a.approvals[0].ID != "ap-2" {
+		t.Fatalf("queue = %+v, want ap-2", a.approvals)
+	}
+
+	a.approvals[0].ExpiresAt = time.Now().Add(-time.Second)
+	a.Update(statusTickMsg{})
+	if len(a.approvals) != 0 {
+		t.Errorf("expired request still queued: %+v", a.approvals)
+	}
+	if strings.Contains(a.View(), "Exec approval") {
+		t.Error("modal still shown with an empty queue")
+	}
+}
+
+func TestApprovalsIgnoredWithoutScope(t *testing.T) {
+	for name, cfg := range map[string]config.Config{
+		"approvals off": {},
+		"read-only":     {Approvals: true, ReadOnly: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := newChatApp(t, 100, 30)
+			a.cfg = &cfg
+			a.Update(approvalRequestMsg{ID: "ap-1", Command: "ls"})
+			a.inbox.push(approvalRequestMsg{ID: "ap-2", Command: "ls"})
+			a.Update(inboxMsg{})
+			if len(a.approvals) != 0 {
+				t.Fatalf("queued %+v", a.approvals)
+			}
+			if strings.Contains(a.View(), "Exec approval") {
+				t.Error("modal shown")
+			}
+			a.approvals = []gateway.ApprovalRequest{{ID: "ap-3"}}
+			a.approvalAt = time.Now().Add(-time.Second)
+			if cmd := a.handleApprovalKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")}); cmd != nil || a.approvalBusy != "" {
+				t.Error("approval key answered a request")
+			}
+		})
+	}
+}
+
+func TestApprovalInboxDrainedOnTick(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.cfg.Approvals = true
+	// The wake-up was dropped because events was full; the tick still
+	// picks the requests up, in order.
+	a.inbox.push(approvalRequestMsg{ID: "ap-1"})
+	a.inbox.push(approvalRequestMsg{ID: "ap-2"})
+	a.inbox.push(approvalResolvedMsg{id: "ap-1"})
+	a.Update(statusTickMsg{})
+	if len(a.approvals) != 1 || a.approvals[0].ID != "ap-2" {
+		t.Fatalf("queue = %+v, want ap-2", a.approvals)
+	}
+}
@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
+)
+
+// checkTimeout bounds each network check.
+const checkTimeout = 5 * time.Second
+
+// check is the outcome of one connection diagnostic.
+type check struct {
+	name    string
+	detail  string // what was found, on success, or why it was skipped
+	err     error
+	skipped bool // the step could not be checked from here
+}
+
+// checksDoneMsg carries the results of runChecks.
+type checksDoneMsg []check
+
+// runChecks walks the path to the gateway one step at a time — config, the
+// tunnel's first hop or command, the gateway itself — so a failed connect
+// can be pinned to a step.
+func runChecks(cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		var out []check
+		if err := cfg.Validate(); err != nil {
+			return checksDoneMsg{{name: "config", err: err}}
+		}
+		out = append(out, check{name: "config", detail: config.FilePath()})
+
+		switch {
+		case cfg.SSHEnabled():
+			addr, err := tunnel.FirstHop(cfg.SSH)
+			if err == nil {
+				err = dialCheck(addr)
+			}
+			out = append(out, check{name: "ssh", detail: "reached " + addr, err: err})
+			// Reaching it would mean bringing the tunnel up, which is what
+			// just failed; say so rather than report it as fine.
+			out = append(out, check{name: "gateway", detail: "not checked: behind the tunnel, at " + cfg.SSH.RemoteAddr(), skipped: true})
+		case cfg.TunnelEnabled():
+			path, err := tunnel.CommandPath(cfg.Tunnel)
+			out = append(out, check{name: "tunnel", detail: path, err: err})
+			out = append(out, check{name: "gateway", detail: "not checked: behind the tunnel", skipped: true})
+		default:
+			addr, err := gatewayAddr(cfg.GatewayURL)
+			if err == nil {
+				err = dialCheck(addr)
+			}
+			out = append(out, check{name: "gateway", detail: "reached " + addr, err: err})
+		}
+		return checksDoneMsg(out)
+	}
+}
+
+// gatewayAddr returns the host:port a gateway URL connects to.
+func gatewayAddr(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("gateway URL %q has no host", raw)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// dialCheck opens and closes a TCP connection to addr.
+func dialCheck(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, checkTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
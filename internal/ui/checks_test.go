@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ngmaloney/clawchat-cli/internal/config"
+)
+
+func TestRunChecks(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	up := "ws://" + ln.Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "ws://" + closed.Addr().String()
+	closed.Close()
+
+	for _, tt := range []struct {
+		url  string
+		fail string // name of the failing check, "" if none
+	}{
+		{url: up},
+		{url: down, fail: "gateway"},
+		{url: "", fail: "config"},
+	} {
+		got := runChecks(&config.Config{GatewayURL: tt.url, Token: "t"})().(checksDoneMsg)
+		failed := ""
+		for _, c := range got {
+			if c.err != nil {
+				failed = c.name
+			}
+		}
+		if failed != tt.fail {
+			t.Errorf("%q: failing check = %q, want %q (%+v)", tt.url, failed, tt.fail, got)
+		}
+	}
+}
+
+func TestGatewayAddr(t *testing.T) {
+	for raw, want := range map[string]string{
+		"ws://example.com:18789": "example.com:18789",
+		"ws://example.com":       "example.com:80",
+		"wss://example.com":      "example.com:443",
+	} {
+		if got, err := gatewayAddr(raw); err != nil || got != want {
+			t.Errorf("gatewayAddr(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	if _, err := gatewayAddr("ws://"); err == nil {
+		t.Error("URL without a host accepted")
+	}
+}
+
+func TestRunChecksBehindTunnel(t *testing.T) {
+	cfg := &config.Config{GatewayURL: "ws://127.0.0.1:18789", Token: "t",
+		Tunnel: &config.Tunnel{Command: "sh -c 'exec sleep 1' {local_port}"}}
+	got := runChecks(cfg)().(checksDoneMsg)
+	last := got[len(got)-1]
+	if last.name != "gateway" || !last.skipped || last.err != nil {
+		t.Errorf("gateway check = %+v, want it marked not checked", last)
+	}
+}
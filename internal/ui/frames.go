@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// frameMsg is the tick that draws changes held back by the frame limiter.
+type frameMsg struct{}
+
+// frameLimiter coalesces streaming redraws to at most one per frame. A
+// change after an idle frame is drawn at once; changes arriving faster are
+// held and drawn together by a single tick at the next frame boundary.
+type frameLimiter struct {
+	interval time.Duration
+	now      func() time.Time // replaced in tests
+
+	last    time.Time // when the transcript was last drawn
+	pending bool      // a frameMsg is on its way
+	dirty   bool      // changes not drawn yet
+}
+
+func newFrameLimiter(fps int) frameLimiter {
+	return frameLimiter{interval: time.Second / time.Duration(fps), now: time.Now}
+}
+
+// request notes a change. It reports whether to draw now; otherwise, if
+// wait is non-zero, a frameMsg should be sent after wait.
+func (f *frameLimiter) request() (draw bool, wait time.Duration) {
+	f.dirty = true
+	if f.pending {
+		return false, 0
+	}
+	since := f.now().Sub(f.last)
+	if since >= f.interval {
+		return true, 0
+	}
+	f.pending = true
+	return false, f.interval - since
+}
+
+// tick handles a frameMsg and reports whether there is anything to draw.
+func (f *frameLimiter) tick() bool {
+	f.pending = false
+	return f.dirty
+}
+
+// drawn records a redraw, whether or not the limiter asked for it, so
+// changes it covered are not drawn again.
+func (f *frameLimiter) drawn() {
+	f.dirty = false
+	f.last = f.now()
+}
+
+// requestFrame draws the transcript now or schedules it for the next frame.
+func (a *App) requestFrame() tea.Cmd {
+	draw, wait := a.frames.request()
+	switch {
+	case draw:
+		a.flushTranscript()
+	case wait > 0:
+		return tea.Tick(wait, func(time.Time) tea.Msg { return frameMsg{} })
+	}
+	return nil
+}
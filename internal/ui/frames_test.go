@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// fakeClock is a settable time source.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestFrameLimiter(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	f := newFrameLimiter(30)
+	f.now = clock.now
+	frame := time.Second / 30
+
+	if draw, _ := f.request(); !draw {
+		t.Fatal("first change after idle was not drawn at once")
+	}
+	f.drawn()
+
+	clock.advance(frame / 4)
+	draw, wait := f.request()
+	if draw || wait != frame-frame/4 {
+		t.Fatalf("change within a frame: draw=%v wait=%v, want a tick after %v", draw, wait, frame-frame/4)
+	}
+	for i := 0; i < 50; i++ {
+		if draw, wait := f.request(); draw || wait != 0 {
+			t.Fatalf("change %d with a tick pending: draw=%v wait=%v", i, draw, wait)
+		}
+	}
+
+	clock.advance(wait)
+	if !f.tick() {
+		t.Fatal("tick did not draw the held changes")
+	}
+	f.drawn()
+	if f.tick() {
+		t.Error("a second tick redrew with nothing new")
+	}
+
+	// A redraw outside the limiter (final, error) covers held changes.
+	clock.advance(frame / 2)
+	f.request()
+	f.drawn()
+	if f.tick() {
+		t.Error("tick redrew changes already flushed")
+	}
+}
+
+// TestStreamDeltasAreRateLimited feeds a burst of deltas within a frame
+// and checks the transcript is drawn once for the first and once more for
+// the rest, while final flushes at once.
+func TestStreamDeltasAreRateLimited(t *testing.T) {
+	a := newChatApp(t, 80, 24)
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	a.frames = newFrameLimiter(30)
+	a.frames.now = clock.now
+
+	if cmd := a.handleChatEvent(gateway.ChatEvent{State: "delta", Content: "alpha"}); cmd != nil || !strings.Contains(a.streamView, "alpha") {
+		t.Fatal("first delta not drawn")
+	}
+
+	ticks := 0
+	for _, text := range []string{"alpha bravo", "alpha bravo charlie", "alpha bravo charlie delta"} {
+		clock.advance(time.Millisecond)
+		if cmd := a.handleChatEvent(gateway.ChatEvent{State: "delta", Content: text}); cmd != nil {
+			ticks++
+		}
+	}
+	if ticks != 1 {
+		t.Errorf("scheduled %d frame ticks for a burst, want 1", ticks)
+	}
+	if strings.Contains(a.streamView, "bravo") {
+		t.Errorf("burst drawn before the frame tick: %q", a.streamView)
+	}
+
+	clock.advance(time.Second / 30)
+	a.Update(frameMsg{})
+	if !strings.Contains(a.streamView, "charlie delta") {
+		t.Errorf("frame tick did not draw the latest delta: %q", a.streamView)
+	}
+
+	// final is drawn straight away even inside the frame.
+	a.handleChatEvent(gateway.ChatEvent{State: "delta", Content: "alpha bravo charlie delta echo"})
+	a.handleChatEvent(gateway.ChatEvent{State: "final", Content: "all done"})
+	if a.streamView != "" || len(a.messages) != 1 || a.messages[0].content != "all done" {
+		t.Fatalf("final not flushed immediately: stream=%q messages=%d", a.streamView, len(a.messages))
+	}
+	if a.frames.tick() {
+		t.Error("pending tick would redraw after final")
+	}
+}
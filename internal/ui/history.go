@@ -0,0 +1,81 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// olderHistoryMsg carries the page of history before the oldest message
+// shown.
+type olderHistoryMsg struct {
+	sessionKey string
+	page       gateway.HistoryPage
+	err        error
+}
+
+// setHistory replaces the transcript with the latest page of history.
+func (a *App) setHistory(page gateway.HistoryPage) {
+	a.messages = make([]renderMsg, 0, len(page.Messages))
+	for _, m := range page.Messages {
+		a.messages = append(a.messages, historyMsg(m))
+	}
+	a.olderCursor = page.Cursor
+	a.loadingOlder = false
+}
+
+// loadOlderCmd fetches the previous page of history once the window
+// reaches the top of the transcript, if there is one and it is not already
+// on its way.
+func (a *App) loadOlderCmd() tea.Cmd {
+	if a.olderCursor == "" || a.loadingOlder || a.client == nil {
+		return nil
+	}
+	if top, line := a.chat.position(a.blocks()); top > 0 || line > 0 {
+		return nil
+	}
+	a.loadingOlder = true
+	client, key, cursor, limit := a.client, a.sessionKey, a.olderCursor, a.cfg.HistoryPageSize()
+	return func() tea.Msg {
+		page, err := client.GetHistory(key, limit, cursor)
+		return olderHistoryMsg{sessionKey: key, page: page, err: err}
+	}
+}
+
+// addOlder puts a page of older history above the transcript, keeping the
+// window on the lines it was showing.
+func (a *App) addOlder(msg olderHistoryMsg) {
+	if msg.sessionKey != a.sessionKey || !a.loadingOlder {
+		return
+	}
+	a.loadingOlder = false
+	if msg.err != nil {
+		a.appendMsg(noteMsg(styleError, "⚠ loading older messages: "+msg.err.Error()))
+		return
+	}
+	older := make([]renderMsg, 0, len(msg.page.Messages))
+	for _, m := range msg.page.Messages {
+		// Pages may overlap where messages share a timestamp.
+		if a.seen(m) {
+			continue
+		}
+		older = append(older, historyMsg(m))
+	}
+	a.messages = append(older, a.messages...)
+	a.chat.inserted(0, len(older))
+	a.olderCursor = msg.page.Cursor
+	a.flushTranscript()
+}
+
+// seen reports whether m is already in the transcript: by ID or, for a
+// message without one, by timestamp, role and content.
+func (a *App) seen(m gateway.Message) bool {
+	if m.ID != "" {
+		return a.indexOfID(m.ID, 0) >= 0
+	}
+	for _, e := range a.messages {
+		if e.role == m.Role && e.content == m.Content && e.timestamp.Equal(m.Timestamp) {
+			return true
+		}
+	}
+	return false
+}
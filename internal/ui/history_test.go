@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+func historyPage(from, to int, cursor string) gateway.HistoryPage {
+	var page gateway.HistoryPage
+	for i := from; i < to; i++ {
+		page.Messages = append(page.Messages, gateway.Message{ID: fmt.Sprintf("m%d", i), Role: "user", Content: fmt.Sprintf("message %d", i)})
+	}
+	page.Cursor = cursor
+	return page
+}
+
+func TestScrollingToTheTopLoadsOlderHistory(t *testing.T) {
+	a := newChatApp(t, 80, 30)
+	a.client = gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.sessionKey = "agent:main:main"
+	a.Update(historyReloadMsg(historyPage(50, 100, "c-50")))
+
+	a.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	if a.loadingOlder {
+		t.Fatal("loaded older history before reaching the top")
+	}
+	a.Update(tea.KeyMsg{Type: tea.KeyHome})
+	if !a.loadingOlder {
+		t.Fatal("reaching the top did not load older history")
+	}
+	if view := ansi.Strip(a.View()); !strings.Contains(view, "loading older messages") {
+		t.Error("no loading indicator")
+	}
+	if got := strings.Count(a.View(), "\n") + 1; got != 30 {
+		t.Errorf("view with the indicator is %d lines, want 30", got)
+	}
+
+	before := ansi.Strip(a.chat.view(a.blocks()))
+	a.Update(olderHistoryMsg{sessionKey: a.sessionKey, page: historyPage(0, 51, "")}) // m50 overlaps
+	if len(a.messages) != 100 || a.messages[0].id != "m0" || a.messages[50].id != "m50" {
+		t.Fatalf("after prepending: %d messages, first %q", len(a.messages), a.messages[0].id)
+	}
+	if after := ansi.Strip(a.chat.view(a.blocks())); after != before {
+		t.Errorf("window moved when older history was added:\n%s\n---\n%s", before, after)
+	}
+	if a.loadingOlder || a.olderCursor != "" {
+		t.Error("loading state not cleared")
+	}
+
+	// The start of the session: nothing more to load.
+	a.Update(tea.KeyMsg{Type: tea.KeyHome})
+	if a.loadingOlder {
+		t.Error("tried to load before the start of the session")
+	}
+}
+
+func TestOlderPageWithoutIDsIsDeduplicated(t *testing.T) {
+	a := newChatApp(t, 80, 30)
+	a.sessionKey = "agent:main:main"
+	ts := time.UnixMilli(2000)
+	a.Update(historyReloadMsg{Messages: []gateway.Message{
+		{Role: "user", Content: "same second", Timestamp: ts},
+		{Role: "assistant", Content: "reply", Timestamp: ts.Add(time.Second)},
+	}, Cursor: "before:2000"})
+
+	a.loadingOlder = true
+	a.Update(olderHistoryMsg{sessionKey: a.sessionKey, page: gateway.HistoryPage{Messages: []gateway.Message{
+		{Role: "user", Content: "earlier", Timestamp: ts.Add(-time.Second)},
+		{Role: "user", Content: "same second", Timestamp: ts},         // overlap
+		{Role: "user", Content: "same second", Timestamp: ts.Add(-1)}, // same words, different message
+	}}})
+	want := "user:earlier | user:same second | user:same second | assistant:reply"
+	if got := transcriptOf(a); got != want {
+		t.Errorf("transcript = %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
+)
+
+// shutdownTimeout bounds how long Shutdown waits for an in-flight connect.
+const shutdownTimeout = 5 * time.Second
+
+// lifecycle owns the gateway client and tunnel so they are torn down on every
+// exit path: a quit from any screen, a signal, or a connect that is still in
+// flight when the program ends.
+type lifecycle struct {
+	ctx    context.Context // cancelled on shutdown; aborts a tunnel start
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // in-flight connects
+
+	mu     sync.Mutex
+	closed bool
+	client *gateway.Client
+	tun    *tunnel.Tunnel
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// begin registers an in-flight connect. It returns false once shutdown has
+// started; otherwise the caller must call end when done.
+func (l *lifecycle) begin() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.wg.Add(1)
+	return true
+}
+
+func (l *lifecycle) end() { l.wg.Done() }
+
+// adoptTunnel hands t to the lifecycle. If shutdown has already started, t
+// is stopped and false is returned.
+func (l *lifecycle) adoptTunnel(t *tunnel.Tunnel) bool {
+	l.mu.Lock()
+	closed := l.closed
+	if !closed {
+		l.tun = t
+	}
+	l.mu.Unlock()
+	if closed {
+		t.Stop()
+	}
+	return !closed
+}
+
+// adoptClient hands c to the lifecycle, closing the client it replaces. If
+// shutdown has already started, c is closed and false is returned.
+func (l *lifecycle) adoptClient(c *gateway.Client) bool {
+	l.mu.Lock()
+	closed := l.closed
+	prev := l.client
+	if !closed {
+		l.client = c
+	}
+	l.mu.Unlock()
+	if closed {
+		c.Close()
+	}
+	if !closed && prev != nil && prev != c {
+		prev.Close()
+	}
+	return !closed
+}
+
+// shutdown closes the client, stops the tunnel and waits, up to
+// shutdownTimeout, for in-flight connects to notice. It is idempotent.
+func (l *lifecycle) shutdown() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	client, tun := l.client, l.tun
+	l.mu.Unlock()
+
+	l.cancel()
+	if client != nil {
+		client.Close()
+	}
+	if tun != nil {
+		tun.Stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+	}
+}
@@ -0,0 +1,304 @@
+package ui
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark"
+	gast "github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdown renders assistant messages at a fixed width.
+type markdown struct {
+	width int
+	tr    *glamour.TermRenderer // nil if the renderer could not be built
+}
+
+// newMarkdown builds a renderer for the given wrap width. If glamour rejects
+// the options, render falls back to plain wrapped text.
+func newMarkdown(width int, dark bool) *markdown {
+	tr, _ := glamour.NewTermRenderer(
+		glamour.WithStyles(markdownStyle(dark)),
+		glamour.WithWordWrap(width),
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		glamour.WithEmoji(),
+	)
+	return &markdown{width: width, tr: tr}
+}
+
+// render returns src as styled terminal text with links made clickable.
+func (m *markdown) render(src string) string {
+	// Tabs have no fixed width in the transcript; expand them in code.
+	src = strings.ReplaceAll(src, "\t", "    ")
+	if m.tr != nil {
+		if out, err := m.tr.Render(src); err == nil {
+			return hyperlink(trimBlankLines(out), linkURLs(src))
+		}
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(src)
+}
+
+// trimBlankLines drops the padded empty lines glamour leaves around a
+// document, so renderings can be joined with a single blank line.
+func trimBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownStyle derives glamour's style from the UI palette.
+func markdownStyle(dark bool) ansi.StyleConfig {
+	p, s := mdDark, styles.DarkStyleConfig
+	if !dark {
+		p, s = mdLight, styles.LightStyleConfig
+	}
+	c := func(col lipgloss.Color) *string { v := string(col); return &v }
+	bold := true
+	zero := uint(0)
+
+	// The message label already separates blocks; no margins of our own.
+	s.Document = ansi.StyleBlock{
+		StylePrimitive: ansi.StylePrimitive{Color: c(p.text)},
+		Margin:         &zero,
+	}
+	s.BlockQuote.Color = c(p.muted)
+	s.Heading = ansi.StyleBlock{
+		StylePrimitive: ansi.StylePrimitive{BlockSuffix: "\n", Color: c(p.heading), Bold: &bold},
+	}
+	s.H1 = ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{Prefix: "# "}}
+	s.HorizontalRule = ansi.StylePrimitive{Color: c(p.muted), Format: "\n────────\n"}
+	s.Link = ansi.StylePrimitive{Color: c(p.link), Underline: &bold}
+	s.LinkText = ansi.StylePrimitive{Color: c(p.link), Bold: &bold}
+	s.Code = ansi.StyleBlock{StylePrimitive: ansi.StylePrimitive{
+		Prefix: " ", Suffix: " ", Color: c(p.heading), BackgroundColor: c(p.codeBg),
+	}}
+	s.CodeBlock = ansi.StyleCodeBlock{
+		StyleBlock: ansi.StyleBlock{
+			StylePrimitive: ansi.StylePrimitive{Color: c(p.text)},
+			Margin:         &zero,
+			Indent:         uintPtr(2),
+		},
+		Chroma: &ansi.Chroma{
+			Text:                ansi.StylePrimitive{Color: c(p.text)},
+			Error:               ansi.StylePrimitive{Color: c(p.err)},
+			Comment:             ansi.StylePrimitive{Color: c(p.subtle)},
+			CommentPreproc:      ansi.StylePrimitive{Color: c(p.heading)},
+			Keyword:             ansi.StylePrimitive{Color: c(p.heading)},
+			KeywordReserved:     ansi.StylePrimitive{Color: c(p.heading)},
+			KeywordNamespace:    ansi.StylePrimitive{Color: c(p.heading)},
+			KeywordType:         ansi.StylePrimitive{Color: c(p.link)},
+			Operator:            ansi.StylePrimitive{Color: c(p.muted)},
+			Punctuation:         ansi.StylePrimitive{Color: c(p.muted)},
+			Name:                ansi.StylePrimitive{Color: c(p.text)},
+			NameBuiltin:         ansi.StylePrimitive{Color: c(p.number)},
+			NameTag:             ansi.StylePrimitive{Color: c(p.heading)},
+			NameAttribute:       ansi.StylePrimitive{Color: c(p.link)},
+			NameClass:           ansi.StylePrimitive{Color: c(p.link), Bold: &bold},
+			NameConstant:        ansi.StylePrimitive{Color: c(p.number)},
+			NameDecorator:       ansi.StylePrimitive{Color: c(p.number)},
+			NameFunction:        ansi.StylePrimitive{Color: c(p.link)},
+			LiteralNumber:       ansi.StylePrimitive{Color: c(p.number)},
+			LiteralString:       ansi.StylePrimitive{Color: c(p.str)},
+			LiteralStringEscape: ansi.StylePrimitive{Color: c(p.number)},
+			GenericDeleted:      ansi.StylePrimitive{Color: c(p.err)},
+			GenericEmph:         ansi.StylePrimitive{Italic: &bold},
+			GenericInserted:     ansi.StylePrimitive{Color: c(p.str)},
+			GenericStrong:       ansi.StylePrimitive{Bold: &bold},
+			GenericSubheading:   ansi.StylePrimitive{Color: c(p.muted)},
+		},
+	}
+	return s
+}
+
+func uintPtr(u uint) *uint { return &u }
+
+// linkParser finds link destinations the same way glamour does (GFM, so
+// bare URLs count too).
+var linkParser = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// linkURLs returns the destinations of the links and autolinks in src.
+func linkURLs(src string) []string {
+	source := []byte(src)
+	doc := linkParser.Parser().Parse(text.NewReader(source))
+	seen := map[string]bool{}
+	var urls []string
+	_ = gast.Walk(doc, func(n gast.Node, entering bool) (gast.WalkStatus, error) {
+		if !entering {
+			return gast.WalkContinue, nil
+		}
+		var url string
+		switch l := n.(type) {
+		case *gast.Link:
+			url = string(l.Destination)
+		case *gast.AutoLink:
+			url = string(l.URL(source))
+		}
+		if isWebURL(url) && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+		return gast.WalkContinue, nil
+	})
+	return urls
+}
+
+var webURL = regexp.MustCompile(`^(?i)(https?|ftp|mailto):`)
+
+func isWebURL(s string) bool { return webURL.MatchString(s) }
+
+// hyperlink wraps each printed occurrence of the urls in an OSC 8 escape so
+// terminals that support it make them clickable. Glamour prints the
+// destination after the link text, so the URL itself is what gets wrapped.
+func hyperlink(rendered string, urls []string) string {
+	if len(urls) == 0 {
+		return rendered
+	}
+	// Longest first, so a URL that prefixes another does not split it.
+	sort.Slice(urls, func(i, j int) bool { return len(urls[i]) > len(urls[j]) })
+	pairs := make([]string, 0, 2*len(urls))
+	for _, u := range urls {
+		pairs = append(pairs, u, "\x1b]8;;"+u+"\x1b\\"+u+"\x1b]8;;\x1b\\")
+	}
+	return strings.NewReplacer(pairs...).Replace(rendered)
+}
+
+// streamRenderer renders a reply while it streams in. Blocks that can no
+// longer change (everything before the last blank line outside a code
+// fence) are rendered once and kept; only the tail is re-rendered on each
+// delta. An unclosed fence in the tail is closed before rendering, so a code
+// block being written shows as code rather than as a paragraph of backticks.
+type streamRenderer struct {
+	md     *markdown
+	stable string // source of the blocks already rendered
+	out    string // their rendering
+}
+
+func (s *streamRenderer) render(md *markdown, content string) string {
+	if s.md != md || !strings.HasPrefix(content, s.stable) {
+		*s = streamRenderer{md: md}
+	}
+	if cut := stableCut(content); cut > len(s.stable) {
+		s.out = joinBlocks(s.out, md.render(content[len(s.stable):cut]))
+		s.stable = content[:cut]
+	}
+	tail := content[len(s.stable):]
+	if fence := openFence(tail); fence != "" {
+		tail += "\n" + fence
+	}
+	if strings.TrimSpace(tail) == "" {
+		return s.out
+	}
+	return joinBlocks(s.out, md.render(tail))
+}
+
+func (s *streamRenderer) reset() { *s = streamRenderer{} }
+
+func joinBlocks(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "\n\n" + b
+}
+
+// stableCut returns the offset just past the last blank line in src that is
+// outside a code fence and not inside a list, or 0 if there is none. Text
+// before it forms complete blocks that later deltas cannot change.
+func stableCut(src string) int {
+	cut, off := 0, 0
+	var fence string
+	prevBlank := false
+	for _, line := range strings.SplitAfter(src, "\n") {
+		off += len(line)
+		if !strings.HasSuffix(line, "\n") {
+			break // the last line is still being written
+		}
+		trimmed := strings.TrimRight(line, "\r\n")
+		if fence != "" {
+			if closesFence(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if f := fenceOpener(trimmed); f != "" {
+			if prevBlank {
+				cut = off - len(line)
+			}
+			fence = f
+			prevBlank = false
+			continue
+		}
+		blank := strings.TrimSpace(trimmed) == ""
+		// A blank line only ends a block if the next one does not continue
+		// it (an indented line may belong to a loose list item).
+		if prevBlank && !blank && !continuesBlock(trimmed) {
+			cut = off - len(line)
+		}
+		prevBlank = blank
+	}
+	return cut
+}
+
+// continuesBlock reports whether line, following a blank line, may still be
+// part of the block before it: an indented continuation or another item of
+// a list.
+func continuesBlock(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || listItem.MatchString(line)
+}
+
+var listItem = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])(\s|$)`)
+
+// openFence returns the fence needed to close a code block left open at the
+// end of src, or "" if every fence is closed.
+func openFence(src string) string {
+	var fence string
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+		} else {
+			fence = fenceOpener(line)
+		}
+	}
+	return fence
+}
+
+var fenceRe = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// fenceOpener returns the fence run (``` or ~~~, possibly longer) if line
+// opens a fenced code block.
+func fenceOpener(line string) string {
+	m := fenceRe.FindStringSubmatch(line)
+	if m == nil {
+		return ""
+	}
+	// A backtick fence's info string may not contain backticks.
+	if m[1][0] == '`' && strings.Contains(line[len(m[0]):], "`") {
+		return ""
+	}
+	return m[1]
+}
+
+// closesFence reports whether line closes a block opened with fence: the
+// same character, at least as many, and nothing else on the line.
+func closesFence(line, fence string) bool {
+	m := fenceRe.FindStringSubmatch(line)
+	return m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+		strings.TrimSpace(line[len(m[0]):]) == ""
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestOpenFence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"plain text", ""},
+		{"```go\nfunc main() {", "```"},
+		{"```go\nfunc main() {}\n```", ""},
+		{"~~~~\ncode\n~~~", "~~~~"},
+		{"~~~~\ncode\n~~~~~\nafter", ""},
+		{"````\n```\nstill code", "````"},
+		{"```\ncode\n``` not a close", "```"},
+		{"inline ``` is not a fence", ""},
+		{"``` info with ` backtick", ""},
+	}
+	for _, tt := range tests {
+		if got := openFence(tt.src); got != tt.want {
+			t.Errorf("openFence(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestStableCut(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // the stable prefix
+	}{
+		{"one paragraph", ""},
+		{"first\n\nsecond", ""}, // "second" may still become a list item
+		{"first\n\nsecond\n", "first\n\n"},
+		{"first\n\nsecond\n\nthird\nfou", "first\n\nsecond\n\n"},
+		{"first\n\n```\ncode\n\nmore code\n", "first\n\n"},
+		{"```\ncode\n\ncode\n```\n\nafter\n", "```\ncode\n\ncode\n```\n\n"},
+		{"- a\n\n- b\n\n  more b\n", ""},
+		{"- a\n\n- b\n\nafter the list\n", "- a\n\n- b\n\n"},
+		{"first\n\n", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.src[:stableCut(tt.src)]; got != tt.want {
+			t.Errorf("stableCut(%q) prefix = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestHyperlink(t *testing.T) {
+	urls := linkURLs("see [docs](https://example.com/docs), https://example.com and <mailto:a@b.c> or [rel](./x)")
+	want := []string{"https://example.com/docs", "https://example.com", "mailto:a@b.c"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Fatalf("linkURLs = %q, want %q", urls, want)
+	}
+
+	got := hyperlink("docs https://example.com/docs and https://example.com.", urls)
+	osc := func(u string) string { return "\x1b]8;;" + u + "\x1b\\" + u + "\x1b]8;;\x1b\\" }
+	if want := "docs " + osc("https://example.com/docs") + " and " + osc("https://example.com") + "."; got != want {
+		t.Errorf("hyperlink = %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownRender(t *testing.T) {
+	md := newMarkdown(40, true)
+	out := md.render("# Title\n\nSome **bold** text and [a link](https://example.com).\n\n```go\nfunc main() {\n\tprintln(1)\n}\n```")
+	plain := ansi.Strip(out)
+
+	for _, want := range []string{"Title", "bold", "https://example.com", "func main() {", "println(1)"} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("rendered output missing %q:\n%s", want, plain)
+		}
+	}
+	if strings.Contains(plain, "**") || strings.Contains(plain, "```") {
+		t.Errorf("markdown syntax left in output:\n%s", plain)
+	}
+	if !strings.Contains(out, "\x1b]8;;https://example.com\x1b\\") {
+		t.Errorf("link not wrapped in OSC 8:\n%q", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if w := ansi.StringWidth(line); w > 40 {
+			t.Errorf("line wider than 40 (%d): %q", w, line)
+		}
+	}
+}
+
+func TestStreamRenderer(t *testing.T) {
+	md := newMarkdown(60, true)
+	src := "Intro paragraph.\n\n```python\nprint('hi')\n\nprint('there')\n```\n\n- one\n- two\n\nDone."
+
+	var s streamRenderer
+	for i := 1; i < len(src); i++ {
+		out := ansi.Strip(s.render(md, src[:i]))
+		if strings.Contains(out, "```") {
+			t.Fatalf("open fence rendered as text at %d:\n%s", i, out)
+		}
+	}
+	if got, want := plainLines(s.render(md, src)), plainLines(md.render(src)); got != want {
+		t.Errorf("streamed render differs from a full render:\n%s\n---\n%s", got, want)
+	}
+
+	// A reply that does not extend the previous one starts over.
+	if got := ansi.Strip(s.render(md, "Other reply")); strings.Contains(got, "Intro") {
+		t.Errorf("stale blocks kept after reset: %q", got)
+	}
+}
+
+// plainLines strips styling and the padding glamour adds to each line.
+func plainLines(s string) string {
+	lines := strings.Split(ansi.Strip(s), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// pickerAction is a session operation started from the picker.
+type pickerAction int
+
+const (
+	pickNone   pickerAction = iota
+	pickNew                 // form: agent, model, label
+	pickRename              // form: label
+	pickReset               // confirm
+	pickDelete              // confirm
+)
+
+// sessionOpMsg reports a finished session operation.
+type sessionOpMsg struct {
+	action  pickerAction
+	session gateway.Session // created, or operated on
+	err     error
+}
+
+// beginPickerAction starts action on the selected session: a form to fill
+// in, or a question to confirm.
+func (a *App) beginPickerAction(action pickerAction) {
+	var selected gateway.Session
+	if a.pickerIdx < len(a.sessions) {
+		selected = a.sessions[a.pickerIdx]
+	} else if action != pickNew {
+		return
+	}
+	if action == pickDelete && selected.Key == a.sessionKey {
+		a.pickerErr = "that is the open session; switch to another one to delete it"
+		return
+	}
+
+	a.pickerAction = action
+	a.pickerField = 0
+	a.pickerForm = nil
+	switch action {
+	case pickNew:
+		a.pickerForm = []textinput.Model{
+			pickerInput("agent", "default agent", ""),
+			pickerInput("model", "default model", ""),
+			pickerInput("label", "optional", ""),
+		}
+	case pickRename:
+		a.pickerForm = []textinput.Model{pickerInput("label", "none", selected.Label)}
+	}
+	if len(a.pickerForm) > 0 {
+		a.pickerForm[0].Focus()
+	}
+}
+
+func pickerInput(name, placeholder, value string) textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = fmt.Sprintf("%-7s", name)
+	ti.Placeholder = placeholder
+	ti.CharLimit = 256
+	ti.SetValue(value)
+	return ti
+}
+
+func (a *App) handlePickerActionKey(msg tea.KeyMsg) tea.Cmd {
+	key := msg.String()
+	if key == "ctrl+c" {
+		a.cleanup()
+		return tea.Quit
+	}
+
+	if a.pickerForm == nil {
+		switch key {
+		case "y":
+			return a.runPickerAction()
+		case "n", "esc":
+			a.pickerAction = pickNone
+		}
+		return nil
+	}
+
+	switch key {
+	case "esc":
+		a.pickerAction = pickNone
+		return nil
+	case "enter":
+		return a.runPickerAction()
+	case "tab", "down":
+		a.focusPickerField(a.pickerField + 1)
+		return nil
+	case "shift+tab", "up":
+		a.focusPickerField(a.pickerField - 1)
+		return nil
+	}
+	var cmd tea.Cmd
+	a.pickerForm[a.pickerField], cmd = a.pickerForm[a.pickerField].Update(msg)
+	return cmd
+}
+
+func (a *App) focusPickerField(i int) {
+	n := len(a.pickerForm)
+	a.pickerForm[a.pickerField].Blur()
+	a.pickerField = (i + n) % n
+	a.pickerForm[a.pickerField].Focus()
+}
+
+// runPickerAction sends the operation to the gateway.
+func (a *App) runPickerAction() tea.Cmd {
+	action, client := a.pickerAction, a.client
+	var selected gateway.Session
+	if a.pickerIdx < len(a.sessions) {
+		selected = a.sessions[a.pickerIdx]
+	}
+	field := func(i int) string { return strings.TrimSpace(a.pickerForm[i].Value()) }
+
+	var op func() (gateway.Session, error)
+	switch action {
+	case pickNew:
+		ns := gateway.NewSession{Agent: field(0), Model: field(1), Label: field(2)}
+		op = func() (gateway.Session, error) { return client.CreateSession(ns) }
+	case pickRename:
+		selected.Label = field(0)
+		op = func() (gateway.Session, error) { return selected, client.RenameSession(selected.Key, selected.Label) }
+	case pickReset:
+		op = func() (gateway.Session, error) { return selected, client.ResetSession(selected.Key) }
+	case pickDelete:
+		op = func() (gateway.Session, error) { return selected, client.DeleteSession(selected.Key) }
+	}
+	a.pickerAction = pickNone
+	a.pickerForm = nil
+	return func() tea.Msg {
+		s, err := op()
+		return sessionOpMsg{action: action, session: s, err: err}
+	}
+}
+
+// sessionOpDone follows up a session operation: a new session is opened,
+// otherwise the list is refreshed.
+func (a *App) sessionOpDone(msg sessionOpMsg) tea.Cmd {
+	if msg.err != nil {
+		a.pickerErr = msg.err.Error()
+		return nil
+	}
+	switch msg.action {
+	case pickNew:
+		return a.switchSessionCmd(msg.session)
+	case pickRename:
+		if msg.session.Key == a.sessionKey {
+			a.session.Label = msg.session.Label
+		}
+	case pickReset:
+		if msg.session.Key == a.sessionKey {
+			a.messages = nil
+			a.olderCursor = ""
+			a.chat.gotoBottom()
+			a.flushTranscript()
+			return tea.Batch(a.openPickerCmd(), a.refreshSessionCmd())
+		}
+	}
+	return a.openPickerCmd()
+}
+
+// viewPickerAction draws the form or question for the operation in progress.
+func (a *App) viewPickerAction() string {
+	var name string
+	if a.pickerIdx < len(a.sessions) {
+		name = a.sessions[a.pickerIdx].Key
+	}
+	var rows []string
+	switch a.pickerAction {
+	case pickNew:
+		rows = append(rows, styleAppTitle.Render("New session"))
+	case pickRename:
+		rows = append(rows, styleAppTitle.Render("Rename "+name))
+	case pickReset:
+		rows = append(rows,
+			styleError.Render("Reset "+name+"?"),
+			styleMessageBody.Render("Its history and context are cleared."),
+		)
+	case pickDelete:
+		rows = append(rows,
+			styleError.Render("Delete "+name+"?"),
+			styleMessageBody.Render("The session and its history are removed."),
+		)
+	}
+	for _, f := range a.pickerForm {
+		rows = append(rows, f.View())
+	}
+	if a.pickerForm != nil {
+		rows = append(rows, "", styleTimestamp.Render("tab: next field   enter: save   esc: cancel"))
+	} else {
+		rows = append(rows, "", styleTimestamp.Render("y: confirm   n: cancel"))
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// pickerApp returns an App showing the session picker over two sessions,
+// the first of them open.
+func pickerApp(t *testing.T) *App {
+	a := newChatApp(t, 100, 30)
+	a.sessionKey = "agent:main:main"
+	a.Update(sessionsLoadedMsg{{Key: "agent:main:main"}, {Key: "agent:main:other", Label: "scratch"}})
+	return a
+}
+
+func keys(a *App, ks ...string) {
+	for _, k := range ks {
+		switch k {
+		case "enter":
+			a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		case "tab":
+			a.Update(tea.KeyMsg{Type: tea.KeyTab})
+		case "down":
+			a.Update(tea.KeyMsg{Type: tea.KeyDown})
+		case "esc":
+			a.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		default:
+			a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		}
+	}
+}
+
+func TestPickerBackAndQuit(t *testing.T) {
+	for _, k := range []string{"esc", "q"} {
+		a := pickerApp(t)
+		keys(a, k)
+		if a.state != stateChat {
+			t.Errorf("%s: state = %v, want back to chat", k, a.state)
+		}
+	}
+
+	a := pickerApp(t)
+	_, cmd := a.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd == nil {
+		t.Fatal("ctrl+c did nothing in the picker")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("ctrl+c did not quit from the picker")
+	}
+}
+
+func TestPickerDeleteAsksFirst(t *testing.T) {
+	a := pickerApp(t)
+
+	keys(a, "d")
+	if a.pickerAction != pickNone || !strings.Contains(ansi.Strip(a.View()), "switch to another one") {
+		t.Error("deleting the open session was not refused")
+	}
+
+	keys(a, "down", "d")
+	if view := ansi.Strip(a.View()); a.pickerAction != pickDelete || !strings.Contains(view, "Delete agent:main:other?") {
+		t.Fatalf("no confirmation:\n%s", view)
+	}
+	keys(a, "n")
+	if a.pickerAction != pickNone || a.state != stateSessionPicker {
+		t.Fatal("n did not cancel")
+	}
+
+	keys(a, "d")
+	_, cmd := a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if cmd == nil || a.pickerAction != pickNone {
+		t.Fatal("y did not delete")
+	}
+
+	a.Update(sessionOpMsg{action: pickDelete, session: a.sessions[1], err: fmt.Errorf("sessions.delete: forbidden")})
+	if !strings.Contains(ansi.Strip(a.View()), "forbidden") {
+		t.Error("failure not shown")
+	}
+}
+
+func TestPickerNewSessionOpensIt(t *testing.T) {
+	a := pickerApp(t)
+	keys(a, "n", "ops", "tab", "claude-opus", "tab", "deploys")
+	f := a.pickerForm
+	if len(f) != 3 || f[0].Value() != "ops" || f[1].Value() != "claude-opus" || f[2].Value() != "deploys" {
+		t.Fatalf("form not filled in: %d fields", len(f))
+	}
+	_, cmd := a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || a.pickerAction != pickNone {
+		t.Fatal("enter did not create the session")
+	}
+
+	a.Update(sessionOpMsg{action: pickNew, session: gateway.Session{Key: "agent:ops:new", Label: "deploys"}})
+	if a.state != stateChat || a.sessionKey != "agent:ops:new" {
+		t.Errorf("new session not opened: state=%v key=%q", a.state, a.sessionKey)
+	}
+}
+
+func TestPickerRenameAndReset(t *testing.T) {
+	a := pickerApp(t)
+	keys(a, "down", "r")
+	if len(a.pickerForm) != 1 || a.pickerForm[0].Value() != "scratch" {
+		t.Fatal("rename form not prefilled with the label")
+	}
+	keys(a, "esc")
+	if a.pickerAction != pickNone || a.state != stateSessionPicker {
+		t.Error("esc did not cancel the rename")
+	}
+
+	a.appendMsg(chatMsg("user", "old history", time.Now()))
+	a.pickerIdx = 0
+	keys(a, "R", "y")
+	a.Update(sessionOpMsg{action: pickReset, session: gateway.Session{Key: a.sessionKey}})
+	if len(a.messages) != 0 {
+		t.Error("resetting the open session kept its transcript")
+	}
+}
@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+	"github.com/ngmaloney/clawchat-cli/internal/tunnel"
+)
+
+// statusTickMsg refreshes the status bar's clocks (the reconnect countdown).
+type statusTickMsg struct{}
+
+// sessionInfoMsg carries fresh metadata (model, levels, usage) for a session.
+type sessionInfoMsg gateway.Session
+
+func statusTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return statusTickMsg{} })
+}
+
+// statusSegments renders each status bar segment by name. A segment with
+// nothing to report returns "" and is left out.
+var statusSegments = map[string]func(a *App) string{
+	"model": func(a *App) string {
+		return labelled("model", a.session.Model)
+	},
+	"thinking": func(a *App) string {
+		return labelled("thinking", a.session.ThinkingLevel)
+	},
+	"verbose": func(a *App) string {
+		return labelled("verbose", a.session.VerboseLevel)
+	},
+	"tokens": func(a *App) string {
+		s := a.session
+		switch {
+		case s.TotalTokens > 0 && s.ContextTokens > 0:
+			pct := 100 * s.TotalTokens / s.ContextTokens
+			return labelled("context", fmt.Sprintf("%s/%s (%d%%)", humanTokens(s.TotalTokens), humanTokens(s.ContextTokens), pct))
+		case s.TotalTokens > 0:
+			return labelled("tokens", humanTokens(s.TotalTokens))
+		}
+		return ""
+	},
+	"latency": func(a *App) string {
+		if a.client == nil || a.client.Latency() == 0 {
+			return ""
+		}
+		return labelled("rtt", a.client.Latency().Round(time.Millisecond).String())
+	},
+	"tunnel": func(a *App) string {
+		if a.tun == nil {
+			return ""
+		}
+		style := styleStatusGood
+		switch a.tunState {
+		case tunnel.StateReconnecting:
+			style = styleStatusWarn
+		case tunnel.StateDown:
+			style = styleStatusBad
+		}
+		return styleStatusLabel.Render(a.tun.Name()+" ") + style.Render(string(a.tunState))
+	},
+	"reconnect": func(a *App) string {
+		if a.client == nil || a.connStatus != gateway.StatusReconnecting {
+			return ""
+		}
+		text := "reconnecting…"
+		if at := a.client.RetryAt(); !at.IsZero() {
+			secs := int(time.Until(at).Seconds() + 0.999)
+			text = fmt.Sprintf("reconnecting in %ds", max(secs, 0))
+		}
+		// Why the previous attempt failed; the status stays on reconnecting.
+		if err := a.client.LastError(); err != nil {
+			text += " (" + err.Error() + ")"
+		}
+		return styleStatusWarn.Render(text)
+	},
+	"connection": func(a *App) string {
+		return labelled("gateway", string(a.connStatus))
+	},
+	"gateway": func(a *App) string {
+		return styleStatusValue.Render(gatewayHost(a.cfg.GatewayURL))
+	},
+	"session": func(a *App) string {
+		return labelled("session", a.sessionKey)
+	},
+}
+
+// viewStatusBar draws the configured segments on one line, or "" when the
+// bar is turned off.
+func (a *App) viewStatusBar() string {
+	names := a.cfg.StatusBar()
+	if len(names) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, name := range names {
+		if seg, ok := statusSegments[name]; ok {
+			if s := seg(a); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	line := strings.Join(parts, styleStatusLabel.Render(" │ "))
+	line = ansi.Truncate(line, max(a.width-2, 0), "…")
+	return styleStatusBar.Width(a.width).Render(line)
+}
+
+// statusBarHeight is the number of lines the status bar takes.
+func (a *App) statusBarHeight() int {
+	if len(a.cfg.StatusBar()) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// refreshSessionCmd re-reads the current session's metadata, which changes
+// as replies use tokens and commands like /model or /thinking take effect.
+func (a *App) refreshSessionCmd() tea.Cmd {
+	client, key := a.client, a.sessionKey
+	if client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		sessions, err := client.ListSessions()
+		if err != nil {
+			return nil
+		}
+		for _, s := range sessions {
+			if s.Key == key {
+				return sessionInfoMsg(s)
+			}
+		}
+		return nil
+	}
+}
+
+func labelled(label, value string) string {
+	if value == "" {
+		return ""
+	}
+	return styleStatusLabel.Render(label+" ") + styleStatusValue.Render(value)
+}
+
+// humanTokens formats a token count compactly: 950, 12.3k, 1.2M.
+func humanTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	case n >= 1000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	}
+	return fmt.Sprint(n)
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+func TestStatusBarSegments(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	a.sessionKey = "agent:main:main"
+	a.Update(sessionInfoMsg{
+		Key: "agent:main:main", Model: "claude-opus", ThinkingLevel: "high", VerboseLevel: "off",
+		TotalTokens: 15300, ContextTokens: 200000,
+	})
+
+	bar := ansi.Strip(a.viewStatusBar())
+	want := "model claude-opus │ thinking high │ verbose off │ context 15.3k/200k (7%)"
+	if !strings.Contains(bar, want) {
+		t.Errorf("status bar = %q, want it to contain %q", bar, want)
+	}
+	if w := ansi.StringWidth(a.viewStatusBar()); w != 120 {
+		t.Errorf("status bar is %d wide, want 120", w)
+	}
+
+	// Info for another session is ignored.
+	a.Update(sessionInfoMsg{Key: "agent:main:other", Model: "other-model"})
+	if strings.Contains(ansi.Strip(a.viewStatusBar()), "other-model") {
+		t.Error("status bar shows another session's model")
+	}
+}
+
+func TestStatusBarConfiguredSegments(t *testing.T) {
+	a := newChatApp(t, 120, 30)
+	a.session = gateway.Session{Model: "m1", ThinkingLevel: "low"}
+	a.sessionKey = "agent:main:main"
+
+	a.cfg.UI.StatusBar = []string{"session", "model"}
+	if bar := ansi.Strip(a.viewStatusBar()); !strings.Contains(bar, "session agent:main:main │ model m1") || strings.Contains(bar, "thinking") {
+		t.Errorf("status bar = %q, want only session then model", bar)
+	}
+
+	if got := strings.Count(a.View(), "\n") + 1; got != 30 {
+		t.Errorf("view with the bar is %d lines, want 30", got)
+	}
+	full := a.chat.height
+	a.cfg.UI.StatusBar = []string{}
+	a.rebuildLayout()
+	if a.viewStatusBar() != "" {
+		t.Error("empty status_bar should hide the bar")
+	}
+	if a.chat.height != full+1 {
+		t.Errorf("transcript height = %d without the bar, want %d", a.chat.height, full+1)
+	}
+	if got, want := strings.Count(a.View(), "\n")+1, 30; got != want {
+		t.Errorf("view is %d lines, want %d", got, want)
+	}
+}
+
+func TestHumanTokens(t *testing.T) {
+	for n, want := range map[int]string{
+		0: "0", 950: "950", 1000: "1k", 12345: "12.3k", 200000: "200k", 1_250_000: "1.2M", 2_000_000: "2M",
+	} {
+		if got := humanTokens(n); got != want {
+			t.Errorf("humanTokens(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -4,15 +4,16 @@ import "github.com/charmbracelet/lipgloss"
 
 var (
 	// ANSI 256-color palette — predictable contrast across all terminals
-	colorOrange    = lipgloss.Color("208") // bright orange — brand
-	colorCyan      = lipgloss.Color("39")  // bright blue-cyan — assistant
-	colorBorder    = lipgloss.Color("34")  // terminal green — borders
-	colorGray      = lipgloss.Color("246") // medium gray — readable muted text
-	colorSubtle    = lipgloss.Color("240") // dark gray — timestamps, faint info
-	colorGreen     = lipgloss.Color("82")  // bright green — connected
-	colorRed       = lipgloss.Color("196") // bright red — errors
-	colorWhite     = lipgloss.Color("255") // near-white
-	colorHeaderBg  = lipgloss.Color("235") // dark gray bg — header bar
+	colorOrange   = lipgloss.Color("208") // bright orange — brand
+	colorCyan     = lipgloss.Color("39")  // bright blue-cyan — assistant
+	colorBorder   = lipgloss.Color("34")  // terminal green — borders
+	colorGray     = lipgloss.Color("246") // medium gray — readable muted text
+	colorSubtle   = lipgloss.Color("240") // dark gray — timestamps, faint info
+	colorGreen    = lipgloss.Color("82")  // bright green — connected
+	colorRed      = lipgloss.Color("196") // bright red — errors
+	colorAmber    = lipgloss.Color("214") // amber — warnings, degraded state
+	colorWhite    = lipgloss.Color("255") // near-white
+	colorHeaderBg = lipgloss.Color("235") // dark gray bg — header bar
 
 	// App title
 	styleAppTitle = lipgloss.NewStyle().
@@ -30,6 +31,11 @@ var (
 				BorderForeground(colorGreen).
 				Padding(0, 1)
 
+	styleInputBoxDisabled = lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(colorSubtle).
+				Padding(0, 1)
+
 	// Header bar — slightly elevated background so it reads as a distinct bar
 	styleHeaderBar = lipgloss.NewStyle().
 			Background(colorHeaderBg).
@@ -65,6 +71,24 @@ var (
 			Padding(0, 1).
 			Bold(true)
 
+	styleBadgeSSHDegraded = styleBadgeSSH.
+				Background(colorAmber)
+
+	styleBadgeSSHDown = styleBadgeSSH.
+				Background(colorRed).
+				Foreground(colorWhite)
+
+	styleBadgeReadOnly = lipgloss.NewStyle().
+				Background(colorAmber).
+				Foreground(lipgloss.Color("0")).
+				Bold(true)
+
+	// Exec approval modal title
+	styleApprovalTitle = lipgloss.NewStyle().
+				Background(colorAmber).
+				Foreground(lipgloss.Color("0")).
+				Bold(true)
+
 	styleBadgeConnected = lipgloss.NewStyle().
 				Foreground(colorGreen).
 				Bold(true)
@@ -72,19 +96,50 @@ var (
 	styleBadgeConnecting = lipgloss.NewStyle().
 				Foreground(colorGray)
 
+	styleBadgeReconnecting = lipgloss.NewStyle().
+				Foreground(colorAmber).
+				Bold(true)
+
+	styleBadgeDisconnected = lipgloss.NewStyle().
+				Foreground(colorRed).
+				Bold(true)
+
+	// Status bar along the bottom
+	styleStatusBar = lipgloss.NewStyle().
+			Background(colorHeaderBg).
+			Foreground(colorWhite).
+			Padding(0, 1)
+
+	// Status bar spans; each carries the bar's background, since a styled
+	// span ends by resetting it.
+	styleStatusLabel = styleStatusBar.UnsetPadding().Foreground(colorGray)
+	styleStatusValue = styleStatusBar.UnsetPadding()
+	styleStatusGood  = styleStatusValue.Foreground(colorGreen)
+	styleStatusWarn  = styleStatusValue.Foreground(colorAmber)
+	styleStatusBad   = styleStatusValue.Foreground(colorRed).Bold(true)
+
 	// Session key in header
 	styleSession = lipgloss.NewStyle().
 			Foreground(colorGray)
 
 	// Message body — explicit foreground so it's visible on any terminal theme
 	styleMessageBody = lipgloss.NewStyle().
-			Foreground(colorWhite)
+				Foreground(colorWhite)
 
 	// Errors
 	styleError = lipgloss.NewStyle().
 			Foreground(colorRed).
 			Bold(true)
 
+	// "New messages below" note in the chat box border
+	styleNewBelow = lipgloss.NewStyle().
+			Foreground(colorCyan).
+			Bold(true)
+
+	// Actionable hints for tunnel problems
+	styleHint = lipgloss.NewStyle().
+			Foreground(colorAmber)
+
 	// Connect / error screens
 	styleConnectTitle = lipgloss.NewStyle().
 				Bold(true).
@@ -96,3 +151,43 @@ var (
 			Padding(1, 3).
 			Width(50)
 )
+
+// mdPalette holds the colours for rendered markdown. The accents are the ones
+// used by the rest of the UI; on light terminals they are darkened so text
+// stays readable.
+type mdPalette struct {
+	text    lipgloss.Color // body text
+	muted   lipgloss.Color // quotes, rules, punctuation
+	subtle  lipgloss.Color // code comments
+	heading lipgloss.Color
+	link    lipgloss.Color
+	codeBg  lipgloss.Color // inline code background
+	str     lipgloss.Color // string literals, inserted lines
+	number  lipgloss.Color // numbers, builtins
+	err     lipgloss.Color // deleted lines, lexer errors
+}
+
+var (
+	mdDark = mdPalette{
+		text:    colorWhite,
+		muted:   colorGray,
+		subtle:  colorSubtle,
+		heading: colorOrange,
+		link:    colorCyan,
+		codeBg:  colorHeaderBg,
+		str:     colorGreen,
+		number:  colorAmber,
+		err:     colorRed,
+	}
+	mdLight = mdPalette{
+		text:    lipgloss.Color("235"),
+		muted:   lipgloss.Color("243"),
+		subtle:  lipgloss.Color("247"),
+		heading: lipgloss.Color("166"),
+		link:    lipgloss.Color("25"),
+		codeBg:  lipgloss.Color("254"),
+		str:     lipgloss.Color("28"),
+		number:  lipgloss.Color("130"),
+		err:     lipgloss.Color("160"),
+	}
+)
@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// syncHistoryLimit is how many recent messages are fetched to fill in a run
+// started by another client.
+const syncHistoryLimit = 10
+
+// historySyncMsg carries recent history to merge into the transcript.
+type historySyncMsg struct {
+	sessionKey string
+	history    []gateway.Message
+}
+
+// historyMsg is a transcript entry for a message loaded from history.
+func historyMsg(m gateway.Message) renderMsg {
+	r := chatMsg(m.Role, m.Content, m.Timestamp)
+	r.id = m.ID
+	return r
+}
+
+// eventTime is when a chat event's message was written, or now if the
+// gateway did not say.
+func eventTime(ev gateway.ChatEvent) time.Time {
+	if ev.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return ev.Timestamp
+}
+
+// addPrompt shows a user message sent by any client. Our own prompt comes
+// back too; it is already shown, so it only picks up the gateway's ID.
+func (a *App) addPrompt(ev gateway.ChatEvent) {
+	a.promptRun = ev.RunID
+	m := chatMsg("user", ev.Content, eventTime(ev))
+	m.id = ev.MessageID
+	// Text alone only identifies our own prompt, while it is in flight;
+	// another client may well send the same words.
+	ours := a.isWaiting || ev.RunID != "" && ev.RunID == a.localRunID
+	if i := a.findMsg(m, 0); i >= 0 && (a.messages[i].id != "" || ours) {
+		a.updateMsg(i, m)
+		return
+	}
+	a.appendMsg(m)
+}
+
+// upsertMsg replaces the message with m's ID, or appends m.
+func (a *App) upsertMsg(m renderMsg) {
+	if m.id != "" {
+		if i := a.findMsg(m, 0); i >= 0 {
+			a.updateMsg(i, m)
+			return
+		}
+	}
+	a.appendMsg(m)
+}
+
+// findMsg returns the index of the transcript entry, from index from on,
+// that is m: the one with its ID, or else the latest one with its role and
+// text that has no ID yet. It returns -1 if there is none.
+func (a *App) findMsg(m renderMsg, from int) int {
+	if i := a.indexOfID(m.id, from); i >= 0 {
+		return i
+	}
+	for i := len(a.messages) - 1; i >= from; i-- {
+		if e := a.messages[i]; e.id == "" && e.role == m.role && e.content == m.content {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOfID returns the index of the entry with the given ID, from index
+// from on, or -1.
+func (a *App) indexOfID(id string, from int) int {
+	if id == "" {
+		return -1
+	}
+	for i := from; i < len(a.messages); i++ {
+		if a.messages[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateMsg brings the entry at i in line with m, which is the same
+// message, re-rendering it only if its text changed.
+func (a *App) updateMsg(i int, m renderMsg) {
+	e := &a.messages[i]
+	if e.id == "" {
+		e.id = m.id
+	}
+	if e.content != m.content {
+		e.content = m.content
+		e.cur, e.prev = rendering{}, rendering{}
+		a.flushTranscript()
+	}
+}
+
+// syncHistoryCmd fetches the session's latest messages for reconcile.
+func (a *App) syncHistoryCmd() tea.Cmd {
+	client, key := a.client, a.sessionKey
+	if client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		page, err := client.GetHistory(key, syncHistoryLimit, "")
+		if err != nil {
+			return nil
+		}
+		return historySyncMsg{sessionKey: key, history: page.Messages}
+	}
+}
+
+// reconcile merges recent history into the transcript without replacing
+// it, so older messages, local notes and the scroll position survive.
+// Messages already shown are matched by ID (or role and text, for ones sent
+// here before the gateway gave them an ID) and the rest are inserted after
+// the last match before them. With no match at all, only the latest
+// exchange is appended.
+func (a *App) reconcile(history []gateway.Message) {
+	at, anchored := -1, false // last entry matched, once there is one
+	for _, h := range history {
+		m := historyMsg(h)
+		from := 0
+		if anchored {
+			from = at + 1
+		}
+		if i := a.findMsg(m, from); i >= 0 {
+			a.updateMsg(i, m)
+			at, anchored = i, true
+			continue
+		}
+		if !anchored {
+			// Older than the first message we share with the gateway.
+			continue
+		}
+		at++
+		a.messages = slices.Insert(a.messages, at, m)
+		a.chat.inserted(at, 1)
+	}
+	if !anchored {
+		last := len(history) - 1
+		for last > 0 && history[last].Role != "user" {
+			last--
+		}
+		for _, h := range history[max(last, 0):] {
+			a.messages = append(a.messages, historyMsg(h))
+			a.chat.added(1)
+		}
+	}
+	a.flushTranscript()
+}
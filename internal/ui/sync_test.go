@@ -0,0 +1,209 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ngmaloney/clawchat-cli/internal/gateway"
+)
+
+// transcriptOf lists the messages as role:content, notes as note:content.
+func transcriptOf(a *App) string {
+	var out []string
+	for _, m := range a.messages {
+		role := m.role
+		if role == "" {
+			role = "note"
+		}
+		out = append(out, role+":"+m.content)
+	}
+	return strings.Join(out, " | ")
+}
+
+func TestOtherClientsRunIsAppendedInPlace(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.sessionKey = "agent:main:main"
+	a.Update(historyReloadMsg{Messages: []gateway.Message{
+		{ID: "m1", Role: "user", Content: "first"},
+		{ID: "m2", Role: "assistant", Content: "reply one"},
+	}})
+	a.appendMsg(noteMsg(styleHint, "local notice"))
+
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "final", Role: "user", MessageID: "m3", Content: "from the web"})
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "delta", Role: "assistant", Content: "streaming"})
+	if len(a.streams) != 1 || a.streams[0].buf != "streaming" {
+		t.Error("other client's reply not streamed")
+	}
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "final", Role: "assistant", MessageID: "m4", Content: "streamed reply"})
+
+	want := "user:first | assistant:reply one | note:local notice | user:from the web | assistant:streamed reply"
+	if got := transcriptOf(a); got != want {
+		t.Errorf("transcript = %q\nwant %q", got, want)
+	}
+
+	// A repeated final for the same message updates it rather than adding one.
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "final", Role: "assistant", MessageID: "m4", Content: "streamed reply, edited"})
+	if len(a.messages) != 5 || a.messages[4].content != "streamed reply, edited" {
+		t.Errorf("transcript = %q", transcriptOf(a))
+	}
+}
+
+func TestOwnPromptEchoIsNotDuplicated(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.Update(gatewayStatusMsg{status: gateway.StatusConnected})
+	a.submit("hello there")
+	a.handleChatEvent(gateway.ChatEvent{RunID: "r1", State: "final", Role: "user", MessageID: "m9", Content: "hello there"})
+	if len(a.messages) != 1 || a.messages[0].id != "m9" {
+		t.Fatalf("transcript = %q, want the prompt once, with its ID", transcriptOf(a))
+	}
+
+	// The same words from another client, when not waiting on our own
+	// prompt, are a new message.
+	a.isWaiting = false
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-2", State: "final", Role: "user", Content: "hello there"})
+	if len(a.messages) != 2 {
+		t.Errorf("transcript = %q, want the other client's prompt added", transcriptOf(a))
+	}
+}
+
+func TestReconcileMergesByID(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.messages = []renderMsg{
+		historyMsg(gateway.Message{ID: "m1", Role: "user", Content: "first"}),
+		historyMsg(gateway.Message{ID: "m2", Role: "assistant", Content: "reply one"}),
+		noteMsg(styleHint, "local notice"),
+		chatMsg("assistant", "reply two", time.Now()),
+	}
+	a.reconcile([]gateway.Message{
+		{ID: "m1", Role: "user", Content: "first"},
+		{ID: "m2", Role: "assistant", Content: "reply one"},
+		{ID: "m3", Role: "user", Content: "second"},
+		{ID: "m4", Role: "assistant", Content: "reply two"},
+	})
+	want := "user:first | assistant:reply one | user:second | note:local notice | assistant:reply two"
+	if got := transcriptOf(a); got != want {
+		t.Errorf("transcript = %q\nwant %q", got, want)
+	}
+	if a.messages[4].id != "m4" {
+		t.Errorf("reply did not pick up its ID: %q", a.messages[4].id)
+	}
+
+	// Nothing in common (e.g. after /clear): only the latest exchange is added.
+	a.messages = nil
+	a.reconcile([]gateway.Message{
+		{ID: "m1", Role: "user", Content: "first"},
+		{ID: "m2", Role: "assistant", Content: "reply one"},
+		{ID: "m3", Role: "user", Content: "second"},
+		{ID: "m4", Role: "assistant", Content: "reply two"},
+	})
+	if got := transcriptOf(a); got != "user:second | assistant:reply two" {
+		t.Errorf("transcript = %q", got)
+	}
+}
+
+func TestInsertAboveWindowKeepsPosition(t *testing.T) {
+	b := newFakeBlocks(2, 2, 2, 2, 2, 2)
+	tr := newTranscript(20, 3)
+	tr.scroll(b, -5)
+	before := visible(&tr, b)
+
+	b.heights = append([]int{2}, b.heights...)
+	tr.inserted(0, 1)
+	after := visible(&tr, b)
+	for i := range before {
+		// Block numbers shift by one; the lines within them stay.
+		if before[i][1:] != after[i][1:] || before[i][0]+1 != after[i][0] {
+			t.Fatalf("window moved: %v -> %v", before, after)
+		}
+	}
+	if tr.unseen != 0 {
+		t.Errorf("insert above counted as %d unseen", tr.unseen)
+	}
+}
+
+func TestConcurrentRunsStreamSeparately(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	// Unconnected: commands fail fast, but are issued.
+	a.client = gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.Update(gatewayStatusMsg{client: a.client, status: gateway.StatusConnected})
+	a.submit("mine")
+	a.Update(sendDoneMsg{runID: "local-1"})
+
+	a.handleChatEvent(gateway.ChatEvent{RunID: "local-1", State: "delta", Role: "assistant", Content: "my reply so far"})
+	a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "delta", Role: "assistant", Content: "their reply"})
+	if len(a.streams) != 2 || a.liveStream("local-1").buf != "my reply so far" {
+		t.Fatalf("another run's delta replaced ours: %d streams", len(a.streams))
+	}
+
+	// Another client's run finishing leaves ours streaming and still ours.
+	cmd := a.handleChatEvent(gateway.ChatEvent{RunID: "web-1", State: "final", Role: "assistant", Content: "their reply"})
+	if _, batch := cmd().(tea.BatchMsg); !batch {
+		t.Error("another client's final did not sync history")
+	}
+	if a.localRunID != "local-1" || len(a.streams) != 1 {
+		t.Fatalf("localRunID = %q, streams = %d", a.localRunID, len(a.streams))
+	}
+
+	// Our final takes the streamed text and needs no history sync.
+	cmd = a.handleChatEvent(gateway.ChatEvent{RunID: "local-1", State: "final", Role: "assistant"})
+	if a.localRunID != "" || len(a.streams) != 0 {
+		t.Errorf("run not finished: localRunID = %q, streams = %d", a.localRunID, len(a.streams))
+	}
+	if last := a.messages[len(a.messages)-1]; last.content != "my reply so far" {
+		t.Errorf("last message = %q", last.content)
+	}
+	if _, batch := cmd().(tea.BatchMsg); batch {
+		t.Error("final for our own run synced history")
+	}
+}
+
+func TestFinalSurvivesFullEventQueue(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.handleChatEvent(gateway.ChatEvent{RunID: "run-1", State: "delta", Role: "assistant", Content: "partial"})
+	for len(a.events) < cap(a.events) {
+		a.events <- frameMsg{}
+	}
+
+	deliver(a.events, a.inbox, chatEventMsg{RunID: "run-1", State: "delta", Role: "assistant", Content: "partial, late"})
+	deliver(a.events, a.inbox, chatEventMsg{RunID: "run-1", State: "final", Role: "assistant", Content: "done"})
+	a.Update(statusTickMsg{})
+	if len(a.streams) != 0 {
+		t.Fatalf("stream still open after the final: %d", len(a.streams))
+	}
+	if last := a.messages[len(a.messages)-1]; last.content != "done" {
+		t.Errorf("last message = %q", last.content)
+	}
+
+	// A delta queued before the final must not reopen the run.
+	a.Update(chatEventMsg{RunID: "run-1", State: "delta", Role: "assistant", Content: "partial"})
+	if len(a.streams) != 0 {
+		t.Error("late delta reopened a finished run")
+	}
+}
+
+func TestFinalBeforeSendDone(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.client = gateway.New(gateway.Options{URL: "ws://127.0.0.1:1"})
+	a.Update(gatewayStatusMsg{client: a.client, status: gateway.StatusConnected})
+	a.submit("hi")
+
+	a.handleChatEvent(gateway.ChatEvent{RunID: "local-1", State: "final", Role: "assistant", Content: "hello"})
+	a.Update(sendDoneMsg{runID: "local-1"})
+	if a.localRunID != "" {
+		t.Errorf("localRunID = %q after its run finished", a.localRunID)
+	}
+}
+
+func TestAbortedRunKeepsPartialReply(t *testing.T) {
+	a := newChatApp(t, 100, 30)
+	a.handleChatEvent(gateway.ChatEvent{RunID: "run-1", State: "delta", Role: "assistant", Content: "half a"})
+	a.handleChatEvent(gateway.ChatEvent{RunID: "run-1", State: "aborted", Role: "assistant"})
+	if len(a.streams) != 0 {
+		t.Fatalf("stream still open after abort: %d", len(a.streams))
+	}
+	if got := transcriptOf(a); got != "assistant:half a" {
+		t.Errorf("transcript = %q", got)
+	}
+}
@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// blocks is what the transcript shows: the messages, then the live stream.
+// blockHeight may render the block; for messages the height is cached with the
+// rendering, so asking again is cheap.
+type blocks interface {
+	count() int
+	block(i int) string
+	blockHeight(i int) int
+}
+
+// transcript is the chat history pane. It never lays out the whole
+// history: the position is kept as the first visible block and how many of
+// its lines are scrolled off, so drawing renders only the blocks in the
+// window and scrolling walks cached heights. When following, the window is
+// pinned to the bottom and laid out from the last block upwards.
+type transcript struct {
+	width, height int
+
+	follow  bool // pinned to the bottom
+	top     int  // first visible block when not following
+	topLine int  // lines of it above the window
+	unseen  int  // messages added below while scrolled back
+}
+
+func newTranscript(width, height int) transcript {
+	return transcript{width: width, height: height, follow: true}
+}
+
+func (t *transcript) setSize(width, height int) {
+	t.width, t.height = width, height
+}
+
+// gotoBottom pins the window to the end of the transcript.
+func (t *transcript) gotoBottom() { t.follow, t.unseen = true, 0 }
+
+// added notes n new messages at the end. When following they simply come
+// into view; otherwise the window stays put and they are counted.
+func (t *transcript) added(n int) {
+	if !t.follow {
+		t.unseen += n
+	}
+}
+
+// inserted notes n messages inserted at index at. Ones above the window
+// shift it down so it keeps showing the same lines; ones below it are
+// counted like new messages.
+func (t *transcript) inserted(at, n int) {
+	if t.follow {
+		return
+	}
+	if at <= t.top {
+		t.top += n
+		return
+	}
+	t.unseen += n
+}
+
+// gotoTop moves the window to the first line.
+func (t *transcript) gotoTop(b blocks) {
+	t.follow, t.top, t.topLine = false, 0, 0
+	t.settle(b)
+}
+
+// scroll moves the window by delta lines, negative is up.
+func (t *transcript) scroll(b blocks, delta int) {
+	if delta == 0 {
+		return
+	}
+	t.top, t.topLine = t.position(b)
+	t.follow = false
+	t.topLine += delta
+	for t.topLine < 0 && t.top > 0 {
+		t.top--
+		t.topLine += b.blockHeight(t.top)
+	}
+	if t.topLine < 0 {
+		t.topLine = 0
+	}
+	n := b.count()
+	for t.top < n && t.topLine >= b.blockHeight(t.top) {
+		t.topLine -= b.blockHeight(t.top)
+		t.top++
+	}
+	t.settle(b)
+}
+
+// settle starts following again once the window reaches the bottom, so new
+// content shows up without another keypress.
+func (t *transcript) settle(b blocks) {
+	rest := -t.topLine
+	for i := t.top; i < b.count(); i++ {
+		if rest += b.blockHeight(i); rest > t.height {
+			return
+		}
+	}
+	t.gotoBottom()
+}
+
+// position returns the first visible block and how many of its lines are
+// above the window.
+func (t *transcript) position(b blocks) (int, int) {
+	if !t.follow {
+		return t.top, t.topLine
+	}
+	need := t.height
+	for i := b.count() - 1; i >= 0; i-- {
+		h := b.blockHeight(i)
+		if h >= need {
+			return i, h - need
+		}
+		need -= h
+	}
+	return 0, 0
+}
+
+// view draws the visible window, padded to the pane's size.
+func (t *transcript) view(b blocks) string {
+	top, skip := t.position(b)
+	lines := make([]string, 0, t.height)
+	for i := top; i < b.count() && len(lines) < t.height; i++ {
+		bl := strings.Split(b.block(i), "\n")
+		if skip > 0 {
+			bl = bl[min(skip, len(bl)):]
+			skip = 0
+		}
+		lines = append(lines, bl[:min(len(bl), t.height-len(lines))]...)
+	}
+	return lipgloss.NewStyle().
+		Width(t.width).
+		Height(t.height).
+		MaxWidth(t.width).
+		Render(strings.Join(lines, "\n"))
+}
+
+// handleKey scrolls for the navigation keys and reports whether it used
+// the key.
+func (t *transcript) handleKey(b blocks, key string) bool {
+	switch key {
+	case "up":
+		t.scroll(b, -1)
+	case "down":
+		t.scroll(b, 1)
+	case "pgup":
+		t.scroll(b, -t.height)
+	case "pgdown":
+		t.scroll(b, t.height)
+	case "ctrl+u":
+		t.scroll(b, -t.height/2)
+	case "ctrl+d":
+		t.scroll(b, t.height/2)
+	case "home":
+		t.gotoTop(b)
+	case "end":
+		t.gotoBottom()
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBlocks is a transcript source of numbered lines, recording which
+// blocks were drawn.
+type fakeBlocks struct {
+	heights []int
+	drawn   map[int]bool
+}
+
+func newFakeBlocks(heights ...int) *fakeBlocks {
+	return &fakeBlocks{heights: heights, drawn: map[int]bool{}}
+}
+
+func (f *fakeBlocks) count() int            { return len(f.heights) }
+func (f *fakeBlocks) blockHeight(i int) int { return f.heights[i] }
+
+func (f *fakeBlocks) block(i int) string {
+	f.drawn[i] = true
+	lines := make([]string, f.heights[i])
+	for j := range lines {
+		lines[j] = fmt.Sprintf("%d.%d", i, j)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// visible returns the non-blank lines of the transcript's window.
+func visible(t *transcript, b blocks) []string {
+	var out []string
+	for _, l := range strings.Split(t.view(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func TestTranscriptScroll(t *testing.T) {
+	b := newFakeBlocks(3, 2, 4, 1)
+	tr := newTranscript(20, 4)
+
+	check := func(what string, want ...string) {
+		t.Helper()
+		if got := visible(&tr, b); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: window = %v, want %v", what, got, want)
+		}
+	}
+
+	check("following", "2.1", "2.2", "2.3", "3.0")
+	tr.scroll(b, -2)
+	check("up 2", "1.1", "2.0", "2.1", "2.2")
+	if tr.follow {
+		t.Error("still following after scrolling up")
+	}
+	tr.scroll(b, -100)
+	check("past the top", "0.0", "0.1", "0.2", "1.0")
+	tr.scroll(b, 3)
+	check("down 3", "1.0", "1.1", "2.0", "2.1")
+	tr.scroll(b, 5)
+	check("past the bottom", "2.1", "2.2", "2.3", "3.0")
+	if !tr.follow {
+		t.Error("not following after reaching the bottom")
+	}
+
+	tr.gotoTop(b)
+	check("home", "0.0", "0.1", "0.2", "1.0")
+	b.heights = append(b.heights, 2) // new content does not move the window
+	check("home after append", "0.0", "0.1", "0.2", "1.0")
+	tr.gotoBottom()
+	check("end", "2.3", "3.0", "4.0", "4.1")
+}
+
+func TestTranscriptShortContent(t *testing.T) {
+	b := newFakeBlocks(1, 1)
+	tr := newTranscript(20, 5)
+	if got := strings.Count(tr.view(b), "\n") + 1; got != 5 {
+		t.Errorf("view is %d lines, want padded to 5", got)
+	}
+	tr.scroll(b, -1)
+	if !tr.follow {
+		t.Error("content shorter than the window should stay pinned")
+	}
+	if got := visible(&tr, b); strings.Join(got, " ") != "0.0 1.0" {
+		t.Errorf("window = %v", got)
+	}
+}
+
+func TestTranscriptDrawsOnlyVisibleBlocks(t *testing.T) {
+	heights := make([]int, 1000)
+	for i := range heights {
+		heights[i] = 3
+	}
+	b := newFakeBlocks(heights...)
+	tr := newTranscript(20, 10)
+	tr.view(b)
+	if len(b.drawn) > 4 {
+		t.Errorf("drew %d blocks for a 10-line window", len(b.drawn))
+	}
+}
+
+// longHistoryApp returns an App holding n messages, alternating user
+// prompts and markdown replies.
+func longHistoryApp(b *testing.B, n int) *App {
+	a := newChatApp(b, 100, 40)
+	ts := time.Now()
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			a.messages = append(a.messages, chatMsg("user", fmt.Sprintf("question %d: how do I do the thing?", i), ts))
+		} else {
+			a.messages = append(a.messages, chatMsg("assistant", fmt.Sprintf("Answer %d uses `code` and **bold**.\n\n- one\n- two\n\n```go\nfmt.Println(%d)\n```", i, i), ts))
+		}
+	}
+	a.flushTranscript()
+	a.View()
+	return a
+}
+
+func BenchmarkStreamDelta5000(b *testing.B) {
+	a := longHistoryApp(b, 5000)
+	reply := strings.Repeat("streaming reply text ", 50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.liveStream("r1").buf = reply[:20+i%(len(reply)-20)]
+		a.flushTranscript()
+		a.View()
+	}
+}
+
+func BenchmarkAppend5000(b *testing.B) {
+	a := longHistoryApp(b, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.appendMsg(chatMsg("user", "another message", time.Now()))
+		a.View()
+	}
+}
+
+func BenchmarkScroll5000(b *testing.B) {
+	a := longHistoryApp(b, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.chat.scroll(a.blocks(), -a.chat.height)
+		a.View()
+	}
+}